@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
 )
@@ -8,22 +9,56 @@ import (
 type Config struct {
 	Gateway GatewayConfig `json:"gateway"`
 	Auth    AuthConfig    `json:"auth"`
+	Storage StorageConfig `json:"storage"`
 }
 
 type GatewayConfig struct {
-	Port     string          `json:"port"`
-	Services []ServiceConfig `json:"services"`
+	Port            string            `json:"port"`
+	Services        []ServiceConfig   `json:"services"`
+	ErrorFormat     string            `json:"error_format"` // envelope (StandardResponse) o problem (application/problem+json)
+	TLS             ListenerTLSConfig `json:"tls"`
+	IdentityHeaders []string          `json:"identity_headers"` // headers que confían los backends; se eliminan de toda request entrante
+}
+
+// HTTPS en el listener del gateway. Los certificados se recargan cuando
+// cambian en disco; los demás cambios requieren reiniciar.
+type ListenerTLSConfig struct {
+	Enabled      bool                `json:"enabled"`
+	CertFile     string              `json:"cert_file"`
+	KeyFile      string              `json:"key_file"`
+	Certificates []CertificateConfig `json:"certificates"` // certificados adicionales, elegidos por SNI
+	MinVersion   string              `json:"min_version"`  // 1.2 o 1.3
+	DisableHTTP2 bool                `json:"disable_http2"`
+	RedirectPort string              `json:"redirect_port"` // listener HTTP que redirige a HTTPS; vacío = sin redirect
+	ClientAuth   string              `json:"client_auth"`   // none, optional o require
+	ClientCAFile string              `json:"client_ca_file"`
+}
+
+type CertificateConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
 }
 
 type ServiceConfig struct {
-	Name         string             `json:"name"`
-	BaseURL      string             `json:"base_url"`
-	Prefix       string             `json:"prefix"`
-	Timeout      int                `json:"timeout"`
-	RateLimit    RateLimitConfig    `json:"rate_limit"`
-	LoadBalancer LoadBalancerConfig `json:"load_balancer"`
-	HealthCheck  HealthCheckConfig  `json:"health_check"`
-	Cache        CacheConfig        `json:"cache"`
+	Name             string             `json:"name"`
+	BaseURL          string             `json:"base_url"`
+	Prefix           string             `json:"prefix"`
+	Timeout          int                `json:"timeout"`
+	RateLimit        RateLimitConfig    `json:"rate_limit"`
+	LoadBalancer     LoadBalancerConfig `json:"load_balancer"`
+	HealthCheck      HealthCheckConfig  `json:"health_check"`
+	Cache            CacheConfig        `json:"cache"`
+	WebSocket        WebSocketConfig    `json:"websocket"`
+	Streaming        StreamingConfig    `json:"streaming"`
+	Retry            RetryConfig        `json:"retry"`
+	Hedging          HedgingConfig      `json:"hedging"`
+	Mirror           MirrorConfig       `json:"mirror"`
+	Rewrite          RewriteConfig      `json:"rewrite"`
+	Routes           []RouteConfig      `json:"routes"`
+	Envelope         EnvelopeConfig     `json:"envelope"`
+	TLS              UpstreamTLSConfig  `json:"tls"`
+	Auth             ServiceAuthConfig  `json:"auth"`
+	PassthroughPaths []string           `json:"passthrough_paths"` // paths (relativos al prefix) que se envían sin transformar
 }
 
 type RateLimitConfig struct {
@@ -33,9 +68,27 @@ type RateLimitConfig struct {
 }
 
 type LoadBalancerConfig struct {
-	Strategy string   `json:"strategy"` // round_robin, random, weighted, least_connections
+	Strategy  string                `json:"strategy"` // round_robin, random, weighted, least_connections
+	Backends  []string              `json:"backends"`
+	Enabled   bool                  `json:"enabled"`
+	Groups    []BackendGroupConfig  `json:"groups"`    // grupos de backends con peso (canary); reemplazan a backends
+	Overrides []GroupOverrideConfig `json:"overrides"` // reglas que fuerzan un grupo
+	StickyBy  string                `json:"sticky_by"` // ip, user_id, header:<nombre>, cookie:<nombre>
+}
+
+type BackendGroupConfig struct {
+	Name     string   `json:"name"`
+	Weight   int      `json:"weight"` // porcentaje del tráfico
 	Backends []string `json:"backends"`
-	Enabled  bool     `json:"enabled"`
+}
+
+// Forzar un grupo según un header, cookie o claim del JWT. Sin value, basta con que exista.
+type GroupOverrideConfig struct {
+	Header string `json:"header"`
+	Cookie string `json:"cookie"`
+	Claim  string `json:"claim"` // user_id, username, role, sub
+	Value  string `json:"value"`
+	Group  string `json:"group"`
 }
 
 type HealthCheckConfig struct {
@@ -46,34 +99,220 @@ type HealthCheckConfig struct {
 }
 
 type CacheConfig struct {
-	Enabled bool `json:"enabled"`
-	TTL     int  `json:"ttl_seconds"`
+	Enabled    bool     `json:"enabled"`
+	TTL        int      `json:"ttl_seconds"`
+	KeyHeaders []string `json:"key_headers"` // headers adicionales que forman parte de la key
+}
+
+type WebSocketConfig struct {
+	IdleTimeoutSeconds int `json:"idle_timeout_seconds"`
+	MaxLifetimeSeconds int `json:"max_lifetime_seconds"`
+}
+
+// Respuestas de larga duración (SSE, NDJSON) que se reenvían a medida que llegan
+type StreamingConfig struct {
+	Paths        []string `json:"paths"`         // paths (relativos al prefix) que siempre se tratan como stream
+	ContentTypes []string `json:"content_types"` // content types adicionales a text/event-stream y application/x-ndjson
+}
+
+// Política de reintentos hacia el servicio
+type RetryConfig struct {
+	MaxAttempts         int      `json:"max_attempts"`           // intentos totales incluyendo el primero; 1 desactiva los reintentos
+	Methods             []string `json:"methods"`                // métodos que se reintentan (por defecto solo los idempotentes)
+	RetryOnStatus       []int    `json:"retry_on_status"`        // status del upstream que se reintentan
+	RetryOnErrors       []string `json:"retry_on_errors"`        // errores de red que se reintentan: connection, timeout
+	InitialBackoffMs    int      `json:"initial_backoff_ms"`     // espera antes del primer reintento
+	MaxBackoffMs        int      `json:"max_backoff_ms"`         // espera máxima entre reintentos
+	BudgetPercent       float64  `json:"budget_percent"`         // reintentos permitidos como % de las requests
+	MinRetriesPerSecond int      `json:"min_retries_per_second"` // reintentos permitidos aunque el budget esté agotado
+}
+
+// Hedging: si el upstream tarda más que delay_ms, enviar una segunda request a otro backend
+type HedgingConfig struct {
+	Enabled    bool     `json:"enabled"`
+	DelayMs    int      `json:"delay_ms"`
+	MaxPercent float64  `json:"max_percent"` // % máximo de requests que pueden generar un hedge
+	Paths      []string `json:"paths"`       // paths (relativos al prefix) con hedging; vacío = todo el servicio
+}
+
+// Mirror: copia asíncrona de las requests a un backend shadow; su respuesta se descarta
+type MirrorConfig struct {
+	Enabled       bool     `json:"enabled"`
+	URL           string   `json:"url"`
+	SamplePercent float64  `json:"sample_percent"` // % de las requests que se copian
+	Methods       []string `json:"methods"`
+	Timeout       int      `json:"timeout"`   // segundos; por defecto el timeout del servicio
+	LogDiffs      bool     `json:"log_diffs"` // loguear diferencias de status entre primary y shadow
+}
+
+// Reescritura del path enviado al upstream. Por defecto se quita el prefix del servicio;
+// las reglas se evalúan en orden sobre ese path y se aplica la primera que coincide.
+type RewriteConfig struct {
+	KeepPrefix bool                `json:"keep_prefix"` // enviar el path con el prefix del servicio
+	Rules      []RewriteRuleConfig `json:"rules"`
+}
+
+// Una regla usa regex o prefix
+type RewriteRuleConfig struct {
+	Regex       string `json:"regex"`  // expresión regular; replacement admite $1 o $nombre
+	Prefix      string `json:"prefix"` // prefijo del path que se reemplaza por replacement
+	Replacement string `json:"replacement"`
+}
+
+// Ruta alternativa dentro del prefix del servicio: las requests que cumplen todas
+// las condiciones de match van a base_url en lugar del backend del servicio
+type RouteConfig struct {
+	Name     string     `json:"name"`
+	Priority int        `json:"priority"` // mayor prioridad se evalúa primero
+	Match    RouteMatch `json:"match"`
+	BaseURL  string     `json:"base_url"`
+	Envelope string     `json:"envelope"` // modo de envelope de la ruta; vacío = el del servicio
+}
+
+// Condiciones de una ruta; las vacías no se evalúan
+type RouteMatch struct {
+	Hosts   []string          `json:"hosts"` // exacto o comodín (*.example.com)
+	Methods []string          `json:"methods"`
+	Headers map[string]string `json:"headers"` // valor vacío = el header solo tiene que estar presente
+	Query   map[string]string `json:"query"`   // valor vacío = el parámetro solo tiene que estar presente
+}
+
+// Formato de las respuestas: wrap (StandardResponse con status 200),
+// wrap_preserve_status (StandardResponse con el status real) o passthrough
+type EnvelopeConfig struct {
+	Mode         string   `json:"mode"`
+	RawAllowlist []string `json:"raw_allowlist"` // IPs o CIDRs que pueden pedir la respuesta sin envelope con X-Raw-Response
+}
+
+// TLS hacia los upstreams https del servicio. Los archivos se recargan
+// cuando cambian en disco, sin reiniciar el gateway.
+type UpstreamTLSConfig struct {
+	CAFile             string `json:"ca_file"`              // bundle PEM de CAs; vacío = CAs del sistema
+	CertFile           string `json:"cert_file"`            // certificado de cliente para mTLS
+	KeyFile            string `json:"key_file"`             // clave privada del certificado de cliente
+	ServerName         string `json:"server_name"`          // SNI y nombre verificado; vacío = host de la URL
+	MinVersion         string `json:"min_version"`          // 1.2 o 1.3
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // solo para desarrollo
+}
+
+// Validación de los claims de los tokens que llegan a un servicio;
+// los campos vacíos usan los de auth
+type ServiceAuthConfig struct {
+	Provider         string   `json:"provider"` // nombre en auth.providers; vacío = local
+	Issuer           string   `json:"issuer"`
+	Audience         []string `json:"audience"`
+	ClockSkewSeconds int      `json:"clock_skew_seconds"`
 }
 
 type AuthConfig struct {
-	Enabled       bool   `json:"enabled"`
-	JWTSecret     string `json:"jwt_secret"`
-	TokenExpiry   int    `json:"token_expiry_hours"`
-	RefreshExpiry int    `json:"refresh_expiry_hours"`
+	Enabled          bool              `json:"enabled"`
+	JWTSecret        string            `json:"jwt_secret"` // clave de HS256
+	TokenExpiry      int               `json:"token_expiry_hours"`
+	RefreshExpiry    int               `json:"refresh_expiry_hours"`
+	Algorithms       []string          `json:"algorithms"`  // HS256, RS256, ES256, EdDSA; por defecto HS256
+	PublicKeys       []PublicKeyConfig `json:"public_keys"` // claves públicas PEM para RS256, ES256 y EdDSA
+	JWKS             JWKSConfig        `json:"jwks"`
+	Issuer           string            `json:"issuer"`             // iss requerido; vacío = no se verifica
+	Audience         []string          `json:"audience"`           // el aud del token debe incluir alguno; vacío = no se verifica
+	ClockSkewSeconds int               `json:"clock_skew_seconds"` // tolerancia para exp y nbf
+
+	Providers []AuthProviderConfig `json:"providers"` // proveedores adicionales que cada servicio puede elegir
+	Users     UserSourceConfig     `json:"users"`     // usuarios de /auth/token; sin type los endpoints de tokens están deshabilitados
+	Denylist  DenylistConfig       `json:"denylist"`
+}
+
+// Tokens revocados por jti o por subject, guardados en el storage compartido
+type DenylistConfig struct {
+	Enabled                 bool `json:"enabled"`
+	MaxTokenLifetimeSeconds int  `json:"max_token_lifetime_seconds"` // vigencia de las entradas sin exp conocido; por defecto el mayor de token_expiry_hours y refresh_expiry_hours
+	FailOpen                bool `json:"fail_open"`                  // si el storage no responde, aceptar el token en lugar de responder 503
+}
+
+// Origen de los usuarios que pueden pedir tokens al gateway
+type UserSourceConfig struct {
+	Type string `json:"type"` // file
+	File string `json:"file"` // JSON con los usuarios y sus passwords en bcrypt; se relee cuando cambia
+}
+
+// Proveedor de autenticación con nombre. El proveedor "local" es el que definen
+// los campos de auth (jwt_secret, public_keys, jwks).
+type AuthProviderConfig struct {
+	Name             string   `json:"name"`
+	Type             string   `json:"type"`   // oidc (JWT con las claves del discovery) o introspection (tokens opacos, RFC 7662)
+	Issuer           string   `json:"issuer"` // base de /.well-known/openid-configuration; en oidc se exige como iss
+	Audience         []string `json:"audience"`
+	ClockSkewSeconds int      `json:"clock_skew_seconds"`
+	Algorithms       []string `json:"algorithms"`        // oidc; por defecto RS256, ES256 y EdDSA
+	RefreshSeconds   int      `json:"refresh_seconds"`   // oidc: refresco del JWKS
+	IntrospectionURL string   `json:"introspection_url"` // introspection; vacío = introspection_endpoint del discovery
+	ClientID         string   `json:"client_id"`
+	ClientSecret     string   `json:"client_secret"`
+	CacheSeconds     int      `json:"cache_seconds"` // introspection: tiempo máximo en cache, nunca más allá del exp del token
+	Timeout          int      `json:"timeout"`       // segundos
+}
+
+type PublicKeyConfig struct {
+	KID  string `json:"kid"`  // se compara con el kid del token; vacío = cualquiera
+	File string `json:"file"` // PEM con la clave pública o un certificado
+}
+
+// JWKS remoto; las claves se cachean y se refrescan en segundo plano
+type JWKSConfig struct {
+	URL            string `json:"url"`
+	RefreshSeconds int    `json:"refresh_seconds"`
+	Timeout        int    `json:"timeout"` // segundos
+}
+
+type StorageConfig struct {
+	Type  string      `json:"type"` // memory, redis
+	Redis RedisConfig `json:"redis"`
+}
+
+type RedisConfig struct {
+	Address   string `json:"address"`
+	Password  string `json:"password"`
+	DB        int    `json:"db"`
+	KeyPrefix string `json:"key_prefix"`
 }
 
 func LoadConfig(path string) (*Config, error) {
-	file, err := os.Open(path)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolver referencias ${VAR} antes de parsear
+	raw, err = interpolateEnv(raw)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
 	var config Config
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(bytes.NewReader(raw))
 	err = decoder.Decode(&config)
 	if err != nil {
 		return nil, err
 	}
 
+	// Keys que no corresponden a ningún campo conocido
+	problems, err := unknownKeys(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Overrides desde variables de entorno
+	if err := config.applyEnvOverrides(); err != nil {
+		return nil, err
+	}
+
 	// Aplicar valores por defecto
 	config.applyDefaults()
 
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
 	return &config, nil
 }
 
@@ -82,9 +321,67 @@ func (c *Config) applyDefaults() {
 		c.Gateway.Port = "8000"
 	}
 
+	if c.Storage.Type == "" {
+		c.Storage.Type = "memory"
+	}
+
+	if c.Gateway.ErrorFormat == "" {
+		c.Gateway.ErrorFormat = "envelope"
+	}
+
+	if len(c.Auth.Algorithms) == 0 {
+		c.Auth.Algorithms = []string{"HS256"}
+	}
+
+	if c.Auth.JWKS.RefreshSeconds == 0 {
+		c.Auth.JWKS.RefreshSeconds = 300
+	}
+
+	if c.Auth.JWKS.Timeout == 0 {
+		c.Auth.JWKS.Timeout = 5
+	}
+
+	// Las entradas de subject deben durar tanto como el token más largo, que
+	// suele ser el refresh token
+	if c.Auth.Denylist.MaxTokenLifetimeSeconds == 0 {
+		c.Auth.Denylist.MaxTokenLifetimeSeconds = max(c.Auth.TokenExpiry, c.Auth.RefreshExpiry) * 3600
+	}
+
+	for i := range c.Auth.Providers {
+		provider := &c.Auth.Providers[i]
+		if len(provider.Algorithms) == 0 && provider.Type == "oidc" {
+			provider.Algorithms = []string{"RS256", "ES256", "EdDSA"}
+		}
+		if provider.RefreshSeconds == 0 {
+			provider.RefreshSeconds = 300
+		}
+		if provider.CacheSeconds == 0 {
+			provider.CacheSeconds = 60
+		}
+		if provider.Timeout == 0 {
+			provider.Timeout = 5
+		}
+	}
+
+	if c.Gateway.TLS.MinVersion == "" {
+		c.Gateway.TLS.MinVersion = "1.2"
+	}
+
+	if c.Gateway.TLS.ClientAuth == "" {
+		c.Gateway.TLS.ClientAuth = "none"
+	}
+
+	if c.Storage.Redis.KeyPrefix == "" {
+		c.Storage.Redis.KeyPrefix = "api-gateway:"
+	}
+
 	for i := range c.Gateway.Services {
 		service := &c.Gateway.Services[i]
 
+		if service.LoadBalancer.Strategy == "" {
+			service.LoadBalancer.Strategy = "round_robin"
+		}
+
 		if service.Timeout == 0 {
 			service.Timeout = 30
 		}
@@ -105,5 +402,77 @@ func (c *Config) applyDefaults() {
 		if service.Cache.TTL == 0 {
 			service.Cache.TTL = 300 // 5 minutos
 		}
+
+		if service.WebSocket.IdleTimeoutSeconds == 0 {
+			service.WebSocket.IdleTimeoutSeconds = 300
+		}
+
+		if service.WebSocket.MaxLifetimeSeconds == 0 {
+			service.WebSocket.MaxLifetimeSeconds = 3600
+		}
+
+		service.Retry.applyDefaults()
+
+		if service.Hedging.DelayMs == 0 {
+			service.Hedging.DelayMs = 100
+		}
+
+		if service.Hedging.MaxPercent == 0 {
+			service.Hedging.MaxPercent = 10
+		}
+
+		if service.Envelope.Mode == "" {
+			service.Envelope.Mode = "wrap"
+		}
+
+		if service.Mirror.SamplePercent == 0 {
+			service.Mirror.SamplePercent = 100
+		}
+
+		if len(service.Mirror.Methods) == 0 {
+			service.Mirror.Methods = []string{"GET", "HEAD"}
+		}
+
+		if service.Mirror.Timeout == 0 {
+			service.Mirror.Timeout = service.Timeout
+		}
+
+		if service.TLS.MinVersion == "" {
+			service.TLS.MinVersion = "1.2"
+		}
+	}
+}
+
+func (r *RetryConfig) applyDefaults() {
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = 3
+	}
+
+	if len(r.Methods) == 0 {
+		r.Methods = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+	}
+
+	if len(r.RetryOnStatus) == 0 {
+		r.RetryOnStatus = []int{502, 503, 504}
+	}
+
+	if len(r.RetryOnErrors) == 0 {
+		r.RetryOnErrors = []string{"connection"}
+	}
+
+	if r.InitialBackoffMs == 0 {
+		r.InitialBackoffMs = 50
+	}
+
+	if r.MaxBackoffMs == 0 {
+		r.MaxBackoffMs = 1000
+	}
+
+	if r.BudgetPercent == 0 {
+		r.BudgetPercent = 20
+	}
+
+	if r.MinRetriesPerSecond == 0 {
+		r.MinRetriesPerSecond = 10
 	}
 }
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ${VAR} o ${VAR:-default}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Variables de entorno históricas que se mantienen como alias
+var legacyEnvAliases = map[string]string{
+	"PORT":          "GATEWAY_PORT",
+	"JWT_SECRET":    "GATEWAY_AUTH_JWT_SECRET",
+	"REDIS_ADDRESS": "GATEWAY_STORAGE_REDIS_ADDRESS",
+}
+
+// Reemplazar referencias ${VAR} en el JSON crudo. Falla si alguna variable
+// referenciada no existe y no tiene valor por defecto.
+func interpolateEnv(raw []byte) ([]byte, error) {
+	missing := make(map[string]bool)
+
+	result := envPattern.ReplaceAllFunc(raw, func(match []byte) []byte {
+		groups := envPattern.FindSubmatch(match)
+		name := string(groups[1])
+
+		value, exists := os.LookupEnv(name)
+		if !exists {
+			if len(groups[2]) == 0 {
+				missing[name] = true
+				return match
+			}
+			value = string(groups[3])
+		}
+
+		// Escapar el valor para que siga siendo un string JSON válido
+		escaped, _ := json.Marshal(value)
+		return escaped[1 : len(escaped)-1]
+	})
+
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("config references undefined environment variables: %s", strings.Join(names, ", "))
+	}
+
+	return result, nil
+}
+
+// Aplicar overrides desde variables de entorno:
+//
+//	GATEWAY_PORT
+//	GATEWAY_TLS_<CAMPO>                  p.ej. GATEWAY_TLS_CERT_FILE
+//	GATEWAY_AUTH_<CAMPO>                 p.ej. GATEWAY_AUTH_JWT_SECRET
+//	GATEWAY_STORAGE_<CAMPO>              p.ej. GATEWAY_STORAGE_REDIS_ADDRESS
+//	GATEWAY_SERVICE_<NOMBRE>_<CAMPO>     p.ej. GATEWAY_SERVICE_LEAD_BASE_URL
+//
+// El nombre del campo es su key JSON en mayúsculas, uniendo structs anidados
+// con "_" (GATEWAY_SERVICE_LEAD_RATE_LIMIT_REQUESTS_PER_SECOND).
+func (c *Config) applyEnvOverrides() error {
+	lookup := envLookup()
+
+	if value, exists := lookup("GATEWAY_PORT"); exists {
+		c.Gateway.Port = value
+	}
+
+	if err := overrideStruct(reflect.ValueOf(&c.Gateway.TLS).Elem(), "GATEWAY_TLS", lookup); err != nil {
+		return err
+	}
+	if err := overrideStruct(reflect.ValueOf(&c.Auth).Elem(), "GATEWAY_AUTH", lookup); err != nil {
+		return err
+	}
+	if err := overrideStruct(reflect.ValueOf(&c.Storage).Elem(), "GATEWAY_STORAGE", lookup); err != nil {
+		return err
+	}
+
+	for i := range c.Gateway.Services {
+		service := &c.Gateway.Services[i]
+		prefix := "GATEWAY_SERVICE_" + envName(service.Name)
+		if err := overrideStruct(reflect.ValueOf(service).Elem(), prefix, lookup); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Buscar una variable considerando los alias históricos; el nombre nuevo gana
+func envLookup() func(name string) (string, bool) {
+	aliases := make(map[string]string)
+	for legacy, current := range legacyEnvAliases {
+		aliases[current] = legacy
+	}
+
+	return func(name string) (string, bool) {
+		if value, exists := os.LookupEnv(name); exists {
+			return value, true
+		}
+		if legacy, exists := aliases[name]; exists {
+			return os.LookupEnv(legacy)
+		}
+		return "", false
+	}
+}
+
+func overrideStruct(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + "_" + envName(tag)
+		fieldValue := v.Field(i)
+
+		if fieldValue.Kind() == reflect.Struct {
+			if err := overrideStruct(fieldValue, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, exists := lookup(name)
+		if !exists {
+			continue
+		}
+		if err := setFromEnv(fieldValue, raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setFromEnv(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// Listas separadas por comas
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			element := reflect.New(v.Type().Elem()).Elem()
+			if element.Kind() == reflect.Slice || element.Kind() == reflect.Struct {
+				return fmt.Errorf("unsupported list type %s", v.Type())
+			}
+			if err := setFromEnv(element, item); err != nil {
+				return err
+			}
+			items = reflect.Append(items, element)
+		}
+		v.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// "ms-lead.v2" -> "MS_LEAD_V2"
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,763 @@
+package config
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problema encontrado en la configuración, con su ruta JSON
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Todos los problemas encontrados en una validación
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, 0, len(errs)+1)
+	lines = append(lines, fmt.Sprintf("invalid config (%d problems):", len(errs)))
+	for _, err := range errs {
+		lines = append(lines, "  - "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+var validStrategies = map[string]bool{
+	"round_robin":       true,
+	"random":            true,
+	"weighted":          true,
+	"least_connections": true,
+}
+
+var validRetryErrors = map[string]bool{
+	"connection": true,
+	"timeout":    true,
+}
+
+var validEnvelopeModes = map[string]bool{
+	"wrap":                 true,
+	"wrap_preserve_status": true,
+	"passthrough":          true,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var validClientAuthModes = map[string]bool{
+	"none":     true,
+	"optional": true,
+	"require":  true,
+}
+
+// Caracteres permitidos en un nombre de header (token de RFC 7230)
+var validHeaderName = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+var validJWTAlgorithms = map[string]bool{
+	"HS256": true,
+	"RS256": true,
+	"ES256": true,
+	"EdDSA": true,
+}
+
+var validAuthProviderTypes = map[string]bool{
+	"oidc":          true,
+	"introspection": true,
+}
+
+// Claims del token que pueden forzar un grupo de backends
+var validOverrideClaims = map[string]bool{
+	"user_id":  true,
+	"username": true,
+	"role":     true,
+	"sub":      true,
+}
+
+var validStorageTypes = map[string]bool{
+	"memory": true,
+	"redis":  true,
+}
+
+// Rutas propias del gateway que no pueden usarse como prefix de un servicio
+var reservedPrefixes = []string{"/health", "/metrics", "/admin"}
+
+// Validate revisa la configuración completa y devuelve todos los problemas
+func (c *Config) Validate() error {
+	if errs := c.validate(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c *Config) validate() ValidationErrors {
+	var errs ValidationErrors
+	add := func(path, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if port, err := strconv.Atoi(c.Gateway.Port); err != nil || port < 1 || port > 65535 {
+		add("gateway.port", "must be a number between 1 and 65535, got %q", c.Gateway.Port)
+	}
+
+	if c.Gateway.TLS.Enabled {
+		validateListenerTLS(c.Gateway.TLS, c.Gateway.Port, add)
+	}
+
+	for i, header := range c.Gateway.IdentityHeaders {
+		if !validHeaderName.MatchString(header) {
+			add(fmt.Sprintf("gateway.identity_headers[%d]", i), "invalid header name %q", header)
+		}
+	}
+
+	if len(c.Gateway.Services) == 0 {
+		add("gateway.services", "at least one service is required")
+	}
+
+	// Con auth.users el gateway atiende /auth/token, /auth/refresh y /auth/revoke
+	reserved := append([]string(nil), reservedPrefixes...)
+	if c.Auth.Users.Type != "" {
+		reserved = append(reserved, "/auth")
+	}
+
+	names := make(map[string]string)
+	prefixes := make(map[string]string)
+	providers := make(map[string]bool)
+	for _, provider := range c.Auth.Providers {
+		providers[provider.Name] = true
+	}
+
+	for i, service := range c.Gateway.Services {
+		path := fmt.Sprintf("gateway.services[%d]", i)
+
+		// Nombre
+		if service.Name == "" {
+			add(path+".name", "is required")
+		} else if previous, exists := names[service.Name]; exists {
+			add(path+".name", "duplicate service name %q (already used by %s)", service.Name, previous)
+		} else {
+			names[service.Name] = path
+		}
+
+		// Prefix
+		switch {
+		case !strings.HasPrefix(service.Prefix, "/"):
+			add(path+".prefix", "must start with /, got %q", service.Prefix)
+		case service.Prefix == "/":
+			add(path+".prefix", "cannot be / because it would shadow every other route")
+		case strings.HasSuffix(service.Prefix, "/"):
+			add(path+".prefix", "must not end with /, got %q", service.Prefix)
+		default:
+			for _, route := range reserved {
+				if service.Prefix == route || strings.HasPrefix(service.Prefix, route+"/") {
+					add(path+".prefix", "%q collides with the gateway route %s", service.Prefix, route)
+				}
+			}
+			for other, otherPath := range prefixes {
+				if prefixesOverlap(service.Prefix, other) {
+					add(path+".prefix", "%q overlaps prefix %q of %s", service.Prefix, other, otherPath)
+				}
+			}
+			prefixes[service.Prefix] = path
+		}
+
+		// URLs
+		if err := validateURL(service.BaseURL); err != nil {
+			add(path+".base_url", "%v", err)
+		}
+
+		if service.Timeout <= 0 {
+			add(path+".timeout", "must be greater than 0")
+		}
+
+		// Rate limit
+		if service.RateLimit.Enabled {
+			if service.RateLimit.RequestsPerSecond <= 0 {
+				add(path+".rate_limit.requests_per_second", "must be greater than 0")
+			}
+			if service.RateLimit.BurstSize <= 0 {
+				add(path+".rate_limit.burst_size", "must be greater than 0")
+			}
+		}
+
+		// Load balancer
+		if !validStrategies[service.LoadBalancer.Strategy] {
+			add(path+".load_balancer.strategy", "unknown strategy %q (valid: round_robin, random, weighted, least_connections)", service.LoadBalancer.Strategy)
+		}
+		if service.LoadBalancer.Enabled && len(service.LoadBalancer.Backends) == 0 && len(service.LoadBalancer.Groups) == 0 {
+			add(path+".load_balancer.backends", "at least one backend is required when the load balancer is enabled")
+		}
+		for j, backend := range service.LoadBalancer.Backends {
+			if err := validateURL(backend); err != nil {
+				add(fmt.Sprintf("%s.load_balancer.backends[%d]", path, j), "%v", err)
+			}
+		}
+		validateBackendGroups(service.LoadBalancer, path+".load_balancer", add)
+
+		// Health check
+		if service.HealthCheck.Enabled && !strings.HasPrefix(service.HealthCheck.Endpoint, "/") {
+			add(path+".health_check.endpoint", "must start with /, got %q", service.HealthCheck.Endpoint)
+		}
+		if service.HealthCheck.IntervalSeconds < 0 {
+			add(path+".health_check.interval_seconds", "must not be negative")
+		}
+		if service.HealthCheck.TimeoutSeconds < 0 {
+			add(path+".health_check.timeout_seconds", "must not be negative")
+		}
+
+		if service.WebSocket.IdleTimeoutSeconds < 0 {
+			add(path+".websocket.idle_timeout_seconds", "must not be negative")
+		}
+		if service.WebSocket.MaxLifetimeSeconds < 0 {
+			add(path+".websocket.max_lifetime_seconds", "must not be negative")
+		}
+
+		for j, passthrough := range service.PassthroughPaths {
+			if !strings.HasPrefix(passthrough, "/") {
+				add(fmt.Sprintf("%s.passthrough_paths[%d]", path, j), "must start with /, got %q", passthrough)
+			}
+		}
+
+		for j, streamPath := range service.Streaming.Paths {
+			if !strings.HasPrefix(streamPath, "/") {
+				add(fmt.Sprintf("%s.streaming.paths[%d]", path, j), "must start with /, got %q", streamPath)
+			}
+		}
+		for j, contentType := range service.Streaming.ContentTypes {
+			if _, _, err := mime.ParseMediaType(contentType); err != nil {
+				add(fmt.Sprintf("%s.streaming.content_types[%d]", path, j), "invalid content type %q", contentType)
+			}
+		}
+
+		// Reintentos
+		if service.Retry.MaxAttempts < 1 {
+			add(path+".retry.max_attempts", "must be at least 1")
+		}
+		for j, method := range service.Retry.Methods {
+			if method == "" || strings.ToUpper(method) != method {
+				add(fmt.Sprintf("%s.retry.methods[%d]", path, j), "must be an uppercase HTTP method, got %q", method)
+			}
+		}
+		for j, status := range service.Retry.RetryOnStatus {
+			if status < 100 || status > 599 {
+				add(fmt.Sprintf("%s.retry.retry_on_status[%d]", path, j), "invalid HTTP status %d", status)
+			}
+		}
+		for j, kind := range service.Retry.RetryOnErrors {
+			if !validRetryErrors[kind] {
+				add(fmt.Sprintf("%s.retry.retry_on_errors[%d]", path, j), "unknown error type %q (valid: connection, timeout)", kind)
+			}
+		}
+		if service.Retry.InitialBackoffMs < 0 {
+			add(path+".retry.initial_backoff_ms", "must not be negative")
+		}
+		if service.Retry.MaxBackoffMs < service.Retry.InitialBackoffMs {
+			add(path+".retry.max_backoff_ms", "must be greater than or equal to initial_backoff_ms")
+		}
+		if service.Retry.BudgetPercent < 0 || service.Retry.BudgetPercent > 100 {
+			add(path+".retry.budget_percent", "must be between 0 and 100")
+		}
+		if service.Retry.MinRetriesPerSecond < 0 {
+			add(path+".retry.min_retries_per_second", "must not be negative")
+		}
+
+		// Hedging
+		backendCount := len(service.LoadBalancer.Backends)
+		for _, group := range service.LoadBalancer.Groups {
+			backendCount += len(group.Backends)
+		}
+		if service.Hedging.Enabled && (!service.LoadBalancer.Enabled || backendCount < 2) {
+			add(path+".hedging.enabled", "requires the load balancer with at least 2 backends")
+		}
+		if service.Hedging.DelayMs < 0 {
+			add(path+".hedging.delay_ms", "must not be negative")
+		}
+		if service.Hedging.MaxPercent < 0 || service.Hedging.MaxPercent > 100 {
+			add(path+".hedging.max_percent", "must be between 0 and 100")
+		}
+		for j, hedgePath := range service.Hedging.Paths {
+			if !strings.HasPrefix(hedgePath, "/") {
+				add(fmt.Sprintf("%s.hedging.paths[%d]", path, j), "must start with /, got %q", hedgePath)
+			}
+		}
+
+		// Mirror
+		if service.Mirror.Enabled {
+			if err := validateURL(service.Mirror.URL); err != nil {
+				add(path+".mirror.url", "%v", err)
+			}
+		}
+		if service.Mirror.SamplePercent < 0 || service.Mirror.SamplePercent > 100 {
+			add(path+".mirror.sample_percent", "must be between 0 and 100")
+		}
+		for j, method := range service.Mirror.Methods {
+			if method == "" || strings.ToUpper(method) != method {
+				add(fmt.Sprintf("%s.mirror.methods[%d]", path, j), "must be an uppercase HTTP method, got %q", method)
+			}
+		}
+		if service.Mirror.Timeout < 0 {
+			add(path+".mirror.timeout", "must not be negative")
+		}
+
+		// Rewrite
+		for j, rule := range service.Rewrite.Rules {
+			rulePath := fmt.Sprintf("%s.rewrite.rules[%d]", path, j)
+			switch {
+			case (rule.Regex == "") == (rule.Prefix == ""):
+				add(rulePath, "exactly one of regex or prefix is required")
+			case rule.Regex != "":
+				if _, err := regexp.Compile(rule.Regex); err != nil {
+					add(rulePath+".regex", "invalid regular expression: %v", err)
+				}
+			case !strings.HasPrefix(rule.Prefix, "/"):
+				add(rulePath+".prefix", "must start with /, got %q", rule.Prefix)
+			}
+		}
+
+		// Rutas
+		routeNames := make(map[string]bool)
+		for j, route := range service.Routes {
+			routePath := fmt.Sprintf("%s.routes[%d]", path, j)
+			switch {
+			case route.Name == "":
+				add(routePath+".name", "is required")
+			case route.Name == "default":
+				add(routePath+".name", "%q is reserved for the service backend", route.Name)
+			case routeNames[route.Name]:
+				add(routePath+".name", "duplicate route name %q", route.Name)
+			}
+			routeNames[route.Name] = true
+
+			if err := validateURL(route.BaseURL); err != nil {
+				add(routePath+".base_url", "%v", err)
+			}
+			if route.Envelope != "" && !validEnvelopeModes[route.Envelope] {
+				add(routePath+".envelope", "unknown mode %q (valid: wrap, wrap_preserve_status, passthrough)", route.Envelope)
+			}
+			if len(route.Match.Hosts) == 0 && len(route.Match.Methods) == 0 && len(route.Match.Headers) == 0 && len(route.Match.Query) == 0 {
+				add(routePath+".match", "at least one of hosts, methods, headers or query is required")
+			}
+			for k, host := range route.Match.Hosts {
+				if host == "" || strings.Contains(host, "/") || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+					add(fmt.Sprintf("%s.match.hosts[%d]", routePath, k), "must be a host name or *.domain, got %q", host)
+				}
+			}
+			for k, method := range route.Match.Methods {
+				if method == "" || strings.ToUpper(method) != method {
+					add(fmt.Sprintf("%s.match.methods[%d]", routePath, k), "must be an uppercase HTTP method, got %q", method)
+				}
+			}
+			for header := range route.Match.Headers {
+				if strings.TrimSpace(header) == "" {
+					add(routePath+".match.headers", "header names must not be empty")
+				}
+			}
+			for param := range route.Match.Query {
+				if param == "" {
+					add(routePath+".match.query", "parameter names must not be empty")
+				}
+			}
+		}
+
+		// TLS
+		if (service.TLS.CertFile == "") != (service.TLS.KeyFile == "") {
+			add(path+".tls", "cert_file and key_file must be set together")
+		}
+		if !validTLSVersion(service.TLS.MinVersion) {
+			add(path+".tls.min_version", "unknown version %q (valid: 1.2, 1.3)", service.TLS.MinVersion)
+		}
+
+		// Claims de los tokens
+		if service.Auth.Provider != "" && service.Auth.Provider != "local" && !providers[service.Auth.Provider] {
+			add(path+".auth.provider", "unknown provider %q", service.Auth.Provider)
+		}
+		if service.Auth.ClockSkewSeconds < 0 {
+			add(path+".auth.clock_skew_seconds", "must not be negative")
+		}
+
+		// Envelope
+		if !validEnvelopeModes[service.Envelope.Mode] {
+			add(path+".envelope.mode", "unknown mode %q (valid: wrap, wrap_preserve_status, passthrough)", service.Envelope.Mode)
+		}
+		for j, entry := range service.Envelope.RawAllowlist {
+			if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+				add(fmt.Sprintf("%s.envelope.raw_allowlist[%d]", path, j), "must be an IP or CIDR, got %q", entry)
+			}
+		}
+
+		// Cache
+		if service.Cache.TTL < 0 {
+			add(path+".cache.ttl_seconds", "must not be negative")
+		}
+		for j, header := range service.Cache.KeyHeaders {
+			if strings.TrimSpace(header) == "" {
+				add(fmt.Sprintf("%s.cache.key_headers[%d]", path, j), "must not be empty")
+			}
+		}
+	}
+
+	// Formato de errores
+	if c.Gateway.ErrorFormat != "envelope" && c.Gateway.ErrorFormat != "problem" {
+		add("gateway.error_format", "unknown format %q (valid: envelope, problem)", c.Gateway.ErrorFormat)
+	}
+
+	// Storage
+	if !validStorageTypes[c.Storage.Type] {
+		add("storage.type", "unknown storage type %q (valid: memory, redis)", c.Storage.Type)
+	}
+	if c.Storage.Type == "redis" && c.Storage.Redis.Address == "" {
+		add("storage.redis.address", "is required when storage.type is redis")
+	}
+
+	// Auth
+	asymmetric := false
+	for i, alg := range c.Auth.Algorithms {
+		if !validJWTAlgorithms[alg] {
+			add(fmt.Sprintf("auth.algorithms[%d]", i), "unsupported algorithm %q (valid: HS256, RS256, ES256, EdDSA)", alg)
+		}
+		asymmetric = asymmetric || (validJWTAlgorithms[alg] && alg != "HS256")
+	}
+	if c.Auth.Enabled {
+		if c.Auth.JWTSecret == "" && slices.Contains(c.Auth.Algorithms, "HS256") {
+			add("auth.jwt_secret", "is required when auth is enabled and HS256 is accepted")
+		}
+		if c.Auth.TokenExpiry <= 0 {
+			add("auth.token_expiry_hours", "must be greater than 0 when auth is enabled")
+		}
+		if asymmetric && len(c.Auth.PublicKeys) == 0 && c.Auth.JWKS.URL == "" {
+			add("auth", "public_keys or jwks.url is required for RS256, ES256 and EdDSA")
+		}
+	}
+	if c.Auth.RefreshExpiry < 0 {
+		add("auth.refresh_expiry_hours", "must not be negative")
+	}
+	for i, key := range c.Auth.PublicKeys {
+		if key.File == "" {
+			add(fmt.Sprintf("auth.public_keys[%d].file", i), "is required")
+		}
+	}
+	if c.Auth.JWKS.URL != "" {
+		if err := validateURL(c.Auth.JWKS.URL); err != nil {
+			add("auth.jwks.url", "%v", err)
+		}
+	}
+	if c.Auth.JWKS.RefreshSeconds < 0 {
+		add("auth.jwks.refresh_seconds", "must not be negative")
+	}
+	if c.Auth.ClockSkewSeconds < 0 {
+		add("auth.clock_skew_seconds", "must not be negative")
+	}
+	validateAuthProviders(c.Auth.Providers, add)
+
+	if c.Auth.Denylist.Enabled && c.Auth.Denylist.MaxTokenLifetimeSeconds <= 0 {
+		add("auth.denylist.max_token_lifetime_seconds", "must be greater than 0 when the denylist is enabled")
+	}
+
+	// Emisión de tokens: el gateway firma con jwt_secret, así que necesita HS256
+	switch c.Auth.Users.Type {
+	case "":
+	case "file":
+		if c.Auth.Users.File == "" {
+			add("auth.users.file", "is required when auth.users.type is file")
+		}
+		if c.Auth.JWTSecret == "" || !slices.Contains(c.Auth.Algorithms, "HS256") {
+			add("auth.users", "issuing tokens requires jwt_secret and HS256 in auth.algorithms")
+		}
+		if c.Auth.TokenExpiry <= 0 {
+			add("auth.token_expiry_hours", "must be greater than 0 when auth.users is configured")
+		}
+		if c.Auth.RefreshExpiry <= 0 {
+			add("auth.refresh_expiry_hours", "must be greater than 0 when auth.users is configured")
+		}
+		// Un subject revocado no debe volver a renovar cuando expira su entrada
+		if c.Auth.Denylist.Enabled && c.Auth.Denylist.MaxTokenLifetimeSeconds < c.Auth.RefreshExpiry*3600 {
+			add("auth.denylist.max_token_lifetime_seconds", "must be at least refresh_expiry_hours when auth.users is configured")
+		}
+	default:
+		add("auth.users.type", "unknown user source %q (valid: file)", c.Auth.Users.Type)
+	}
+
+	return errs
+}
+
+// Grupos de backends (canary): nombres únicos, pesos que suman 100 y overrides válidos
+func validateBackendGroups(lb LoadBalancerConfig, path string, add func(path, format string, args ...interface{})) {
+	if len(lb.Groups) == 0 {
+		if len(lb.Overrides) > 0 {
+			add(path+".overrides", "requires groups")
+		}
+		return
+	}
+	if len(lb.Backends) > 0 {
+		add(path+".backends", "must be empty when groups are configured")
+	}
+
+	groups := make(map[string]bool)
+	total := 0
+	for i, group := range lb.Groups {
+		groupPath := fmt.Sprintf("%s.groups[%d]", path, i)
+		if group.Name == "" {
+			add(groupPath+".name", "is required")
+		} else if groups[group.Name] {
+			add(groupPath+".name", "duplicate group name %q", group.Name)
+		}
+		groups[group.Name] = true
+
+		if group.Weight < 0 || group.Weight > 100 {
+			add(groupPath+".weight", "must be between 0 and 100")
+		}
+		total += group.Weight
+
+		if len(group.Backends) == 0 {
+			add(groupPath+".backends", "at least one backend is required")
+		}
+		for j, backend := range group.Backends {
+			if err := validateURL(backend); err != nil {
+				add(fmt.Sprintf("%s.backends[%d]", groupPath, j), "%v", err)
+			}
+		}
+	}
+	if total != 100 {
+		add(path+".groups", "weights must add up to 100, got %d", total)
+	}
+
+	for i, override := range lb.Overrides {
+		overridePath := fmt.Sprintf("%s.overrides[%d]", path, i)
+		sources := 0
+		for _, source := range []string{override.Header, override.Cookie, override.Claim} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			add(overridePath, "exactly one of header, cookie or claim is required")
+		}
+		if override.Claim != "" && !validOverrideClaims[override.Claim] {
+			add(overridePath+".claim", "unsupported claim %q (valid: user_id, username, role, sub)", override.Claim)
+		}
+		if !groups[override.Group] {
+			add(overridePath+".group", "unknown group %q", override.Group)
+		}
+	}
+
+	switch {
+	case lb.StickyBy == "", lb.StickyBy == "ip", lb.StickyBy == "user_id":
+	case strings.HasPrefix(lb.StickyBy, "header:") && len(lb.StickyBy) > len("header:"):
+	case strings.HasPrefix(lb.StickyBy, "cookie:") && len(lb.StickyBy) > len("cookie:"):
+	default:
+		add(path+".sticky_by", "unknown value %q (valid: ip, user_id, header:<name>, cookie:<name>)", lb.StickyBy)
+	}
+}
+
+// Proveedores de auth.providers: nombres únicos y los campos que exige cada tipo
+func validateAuthProviders(providers []AuthProviderConfig, add func(path, format string, args ...interface{})) {
+	names := make(map[string]bool)
+	for i, provider := range providers {
+		path := fmt.Sprintf("auth.providers[%d]", i)
+		switch {
+		case provider.Name == "":
+			add(path+".name", "is required")
+		case provider.Name == "local":
+			add(path+".name", "%q is reserved for the provider defined by auth", provider.Name)
+		case names[provider.Name]:
+			add(path+".name", "duplicate provider name %q", provider.Name)
+		}
+		names[provider.Name] = true
+
+		if !validAuthProviderTypes[provider.Type] {
+			add(path+".type", "unknown provider type %q (valid: oidc, introspection)", provider.Type)
+		}
+		if provider.Issuer != "" {
+			if err := validateURL(provider.Issuer); err != nil {
+				add(path+".issuer", "%v", err)
+			}
+		}
+
+		switch provider.Type {
+		case "oidc":
+			if provider.Issuer == "" {
+				add(path+".issuer", "is required for oidc providers")
+			}
+			for j, alg := range provider.Algorithms {
+				if !validJWTAlgorithms[alg] || alg == "HS256" {
+					add(fmt.Sprintf("%s.algorithms[%d]", path, j), "unsupported algorithm %q (valid: RS256, ES256, EdDSA)", alg)
+				}
+			}
+		case "introspection":
+			if provider.IntrospectionURL == "" && provider.Issuer == "" {
+				add(path, "introspection_url or issuer is required for introspection providers")
+			}
+			if provider.IntrospectionURL != "" {
+				if err := validateURL(provider.IntrospectionURL); err != nil {
+					add(path+".introspection_url", "%v", err)
+				}
+			}
+			if provider.ClientID == "" {
+				add(path+".client_id", "is required for introspection providers")
+			}
+		}
+
+		if provider.ClockSkewSeconds < 0 {
+			add(path+".clock_skew_seconds", "must not be negative")
+		}
+		if provider.RefreshSeconds < 0 {
+			add(path+".refresh_seconds", "must not be negative")
+		}
+		if provider.CacheSeconds < 0 {
+			add(path+".cache_seconds", "must not be negative")
+		}
+		if provider.Timeout < 0 {
+			add(path+".timeout", "must not be negative")
+		}
+	}
+}
+
+func validateListenerTLS(cfg ListenerTLSConfig, port string, add func(path, format string, args ...interface{})) {
+	if cfg.CertFile == "" && len(cfg.Certificates) == 0 {
+		add("gateway.tls", "cert_file or certificates is required when enabled")
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		add("gateway.tls", "cert_file and key_file must be set together")
+	}
+	for i, cert := range cfg.Certificates {
+		if cert.CertFile == "" || cert.KeyFile == "" {
+			add(fmt.Sprintf("gateway.tls.certificates[%d]", i), "cert_file and key_file are required")
+		}
+	}
+	if !validTLSVersion(cfg.MinVersion) {
+		add("gateway.tls.min_version", "unknown version %q (valid: 1.2, 1.3)", cfg.MinVersion)
+	}
+
+	if !validClientAuthModes[cfg.ClientAuth] {
+		add("gateway.tls.client_auth", "unknown mode %q (valid: none, optional, require)", cfg.ClientAuth)
+	} else if cfg.ClientAuth != "none" && cfg.ClientCAFile == "" {
+		add("gateway.tls.client_ca_file", "is required when client_auth is %q", cfg.ClientAuth)
+	}
+
+	if cfg.RedirectPort != "" {
+		if redirect, err := strconv.Atoi(cfg.RedirectPort); err != nil || redirect < 1 || redirect > 65535 {
+			add("gateway.tls.redirect_port", "must be a number between 1 and 65535, got %q", cfg.RedirectPort)
+		} else if cfg.RedirectPort == port {
+			add("gateway.tls.redirect_port", "must be different from gateway.port")
+		}
+	}
+}
+
+func validTLSVersion(version string) bool {
+	_, ok := tlsVersions[version]
+	return ok
+}
+
+// Constante de crypto/tls para un min_version ya validado
+func TLSVersion(version string) uint16 {
+	return tlsVersions[version]
+}
+
+func validateURL(raw string) error {
+	if raw == "" {
+		return fmt.Errorf("is required")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("invalid URL %q: must not include query or fragment", raw)
+	}
+	return nil
+}
+
+// Dos prefixes se solapan si uno es prefijo textual del otro (/lead y /leads)
+func prefixesOverlap(a, b string) bool {
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// Buscar keys del JSON que no corresponden a ningún campo de Config
+func unknownKeys(raw []byte) (ValidationErrors, error) {
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, err
+	}
+
+	var errs ValidationErrors
+	collectUnknownKeys(document, reflect.TypeOf(Config{}), "", &errs)
+	return errs, nil
+}
+
+func collectUnknownKeys(value interface{}, t reflect.Type, path string, errs *ValidationErrors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if tag != "" && tag != "-" {
+				fields[tag] = t.Field(i).Type
+			}
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			fieldType, known := fields[key]
+			if !known {
+				*errs = append(*errs, ValidationError{Path: keyPath, Message: "unknown key"})
+				continue
+			}
+			collectUnknownKeys(object[key], fieldType, keyPath, errs)
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			collectUnknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, item := range object {
+			collectUnknownKeys(item, t.Elem(), path+"."+key, errs)
+		}
+	}
+}
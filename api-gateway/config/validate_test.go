@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Paths de los problemas que LoadConfig encuentra en la config
+func validationPaths(t *testing.T, raw string) []string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadConfig(path)
+
+	var problems ValidationErrors
+	if err != nil && !errors.As(err, &problems) {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	paths := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	return paths
+}
+
+func TestValidateGroupOverrideClaims(t *testing.T) {
+	const claimPath = "gateway.services[0].load_balancer.overrides[0].claim"
+
+	tests := []struct {
+		claim string
+		valid bool
+	}{
+		{"user_id", true},
+		{"role", true},
+		{"sub", true},
+		{"tenant", false},
+		{"beta", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.claim, func(t *testing.T) {
+			paths := validationPaths(t, `{
+  "gateway": {"services": [{
+    "name": "leads", "prefix": "/leads", "base_url": "http://127.0.0.1:1",
+    "load_balancer": {
+      "enabled": true,
+      "groups": [
+        {"name": "stable", "weight": 100, "backends": ["http://127.0.0.1:1"]},
+        {"name": "canary", "weight": 0, "backends": ["http://127.0.0.1:2"]}
+      ],
+      "overrides": [{"claim": "`+tt.claim+`", "group": "canary"}]
+    }
+  }]}
+}`)
+			if rejected := slices.Contains(paths, claimPath); rejected == tt.valid {
+				t.Errorf("problems = %v, want claim valid = %v", paths, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateReservedPrefixes(t *testing.T) {
+	const prefixPath = "gateway.services[0].prefix"
+
+	tests := []struct {
+		name     string
+		prefix   string
+		users    bool // auth.users configurado
+		rejected bool
+	}{
+		{"admin", "/admin", false, true},
+		{"under metrics", "/metrics/extra", false, true},
+		{"auth without token issuance", "/auth", false, false},
+		{"auth with token issuance", "/auth", true, true},
+		{"under auth with token issuance", "/auth/sso", true, true},
+		{"similar to auth", "/authors", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := ""
+			if tt.users {
+				users = `"users": {"type": "file", "file": "users.json"},`
+			}
+			paths := validationPaths(t, `{
+  "gateway": {"services": [{"name": "svc", "prefix": "`+tt.prefix+`", "base_url": "http://127.0.0.1:1"}]},
+  "auth": {`+users+` "jwt_secret": "reserved-check", "token_expiry_hours": 1, "refresh_expiry_hours": 24}
+}`)
+			if rejected := slices.Contains(paths, prefixPath); rejected != tt.rejected {
+				t.Errorf("problems = %v, want prefix rejected = %v", paths, tt.rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"api-gateway/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Body de POST /admin/denylist
+type denylistRequest struct {
+	Type      string    `json:"type"` // jti o subject
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expires_at"` // solo jti: exp del token; vacío = max_token_lifetime_seconds
+}
+
+func (gw *APIGateway) addDenylistEntry(c echo.Context) error {
+	denylist, err := gw.denylist()
+	if err != nil {
+		return err
+	}
+
+	var req denylistRequest
+	if err := c.Bind(&req); err != nil || !middleware.ValidDenylistType(req.Type) || req.Value == "" {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, "body must include type (jti or subject) and value")
+	}
+
+	entry, err := denylist.Add(c.Request().Context(), middleware.DenylistEntry{
+		Type:      req.Type,
+		Value:     req.Value,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if errors.Is(err, middleware.ErrInvalidDenylistEntry) {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, err.Error())
+	}
+	if err != nil {
+		return denylistUnavailable(err)
+	}
+	fmt.Printf("🚫 Denylist entry added: %s %s (until %s)\n", entry.Type, entry.Value, entry.ExpiresAt.Format(time.RFC3339))
+
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data:         entry,
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+func (gw *APIGateway) getDenylistEntry(c echo.Context) error {
+	denylist, err := gw.denylist()
+	if err != nil {
+		return err
+	}
+
+	entry, found, err := denylist.Get(c.Request().Context(), c.Param("type"), c.Param("value"))
+	if err != nil {
+		return denylistUnavailable(err)
+	}
+	if !found {
+		return gatewayError(http.StatusNotFound, middleware.CodeNotFound, fmt.Sprintf("no denylist entry for %s %s", c.Param("type"), c.Param("value")))
+	}
+
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data:         entry,
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+func (gw *APIGateway) removeDenylistEntry(c echo.Context) error {
+	denylist, err := gw.denylist()
+	if err != nil {
+		return err
+	}
+
+	entryType, value := c.Param("type"), c.Param("value")
+	if !middleware.ValidDenylistType(entryType) {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, "type must be jti or subject")
+	}
+	if err := denylist.Remove(c.Request().Context(), entryType, value); err != nil {
+		return denylistUnavailable(err)
+	}
+	fmt.Printf("✅ Denylist entry removed: %s %s\n", entryType, value)
+
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data: map[string]interface{}{
+			"type":    entryType,
+			"value":   value,
+			"removed": true,
+		},
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+// Los endpoints de la denylist solo existen si auth.denylist está habilitada
+func (gw *APIGateway) denylist() (*middleware.Denylist, error) {
+	denylist := gw.current().proxyHandler.Auth().Denylist()
+	if denylist == nil {
+		return nil, gatewayError(http.StatusNotFound, middleware.CodeNotFound, "token denylist is not enabled (auth.denylist)")
+	}
+	return denylist, nil
+}
+
+func denylistUnavailable(err error) error {
+	return middleware.NewGatewayError(http.StatusServiceUnavailable, middleware.LayerGateway, middleware.CodeAuthUnavailable, "Token denylist unavailable").WithCause(err)
+}
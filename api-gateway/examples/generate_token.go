@@ -7,6 +7,7 @@ import (
 
 	"api-gateway/config"
 	"api-gateway/middleware"
+	"api-gateway/storage"
 )
 
 func main() {
@@ -17,7 +18,10 @@ func main() {
 	}
 
 	// Crear middleware de auth
-	authMiddleware := middleware.NewAuthMiddleware(&cfg.Auth)
+	authMiddleware, err := middleware.NewAuthMiddleware(&cfg.Auth, storage.NewMemoryStore())
+	if err != nil {
+		log.Fatal("Error creating auth middleware:", err)
+	}
 
 	if len(os.Args) < 4 {
 		fmt.Println("Uso: go run generate_token.go <user_id> <username> <role>")
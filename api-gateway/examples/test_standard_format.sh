@@ -53,8 +53,8 @@ echo ""
 
 # Test 3: Métricas del Gateway
 echo -e "${BLUE}📈 3. Métricas del Gateway${NC}"
-echo "Probando: $BASE_URL/metrics"
-response=$(curl -s -X GET "$BASE_URL/metrics")
+echo "Probando: $BASE_URL/metrics/json"
+response=$(curl -s -X GET "$BASE_URL/metrics/json")
 echo "$response" | jq . 2>/dev/null || echo "$response"
 check_standard_format "$response" "Gateway Metrics"
 echo ""
@@ -179,7 +179,7 @@ echo ""
 echo -e "${YELLOW}📍 Endpoints del Gateway:${NC}"
 echo "  - Gateway Health: $BASE_URL/health"
 echo "  - Services Health: $BASE_URL/health/services"
-echo "  - Gateway Metrics: $BASE_URL/metrics"
+echo "  - Gateway Metrics: $BASE_URL/metrics (Prometheus), $BASE_URL/metrics/json"
 echo "  - Lead Service: $BASE_URL/api/lead/*"
 echo "  - reCAPTCHA Service: $BASE_URL/ms-validate-recaptcha/api/*"
 echo ""
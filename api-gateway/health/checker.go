@@ -21,6 +21,7 @@ type ServiceHealth struct {
 	LastCheck time.Time
 	Interval  time.Duration
 	Error     string
+	client    *http.Client
 }
 
 func NewChecker() *Checker {
@@ -32,15 +33,22 @@ func NewChecker() *Checker {
 	}
 }
 
-func (hc *Checker) AddService(name, healthURL string, interval time.Duration) {
+// transport es el del servicio (con su configuración TLS); nil usa el cliente por defecto
+func (hc *Checker) AddService(name, healthURL string, interval time.Duration, transport http.RoundTripper) {
 	hc.mutex.Lock()
 	defer hc.mutex.Unlock()
 
+	client := hc.client
+	if transport != nil {
+		client = &http.Client{Transport: transport, Timeout: hc.client.Timeout}
+	}
+
 	hc.services[name] = &ServiceHealth{
 		Name:     name,
 		URL:      healthURL,
 		Healthy:  true,
 		Interval: interval,
+		client:   client,
 	}
 
 	fmt.Printf("📊 Health check added for service: %s -> %s (every %v)\n", name, healthURL, interval)
@@ -89,7 +97,7 @@ func (hc *Checker) performHealthCheck(service *ServiceHealth) {
 		return
 	}
 
-	resp, err := hc.client.Do(req)
+	resp, err := service.client.Do(req)
 	if err != nil {
 		hc.updateServiceHealth(service.Name, false, fmt.Sprintf("Request failed: %v", err))
 		return
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/config"
+)
+
+// Cada cuánto se revisa si cambiaron los certificados del listener en disco
+const certReloadCheckInterval = 10 * time.Second
+
+var clientAuthModes = map[string]tls.ClientAuthType{
+	"none":     tls.NoClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+	"require":  tls.RequireAndVerifyClientCert,
+}
+
+// Configuración TLS del listener HTTPS. Cada handshake usa la configuración
+// vigente, que se reconstruye cuando cambian los certificados en disco.
+type listenerTLS struct {
+	cfg       config.ListenerTLSConfig
+	current   atomic.Pointer[tls.Config]
+	mutex     sync.Mutex
+	modTime   time.Time // última modificación de los archivos cargados
+	checkedAt time.Time
+}
+
+func newListenerTLS(cfg config.ListenerTLSConfig) (*listenerTLS, error) {
+	l := &listenerTLS{cfg: cfg}
+
+	modTime, err := l.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := l.build()
+	if err != nil {
+		return nil, err
+	}
+	l.current.Store(tlsConfig)
+	l.modTime = modTime
+	l.checkedAt = time.Now()
+	return l, nil
+}
+
+// Configuración para el http.Server
+func (l *listenerTLS) serverConfig() *tls.Config {
+	return &tls.Config{
+		NextProtos: l.nextProtos(),
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			l.reloadIfChanged()
+			return l.current.Load(), nil
+		},
+	}
+}
+
+func (l *listenerTLS) nextProtos() []string {
+	if l.cfg.DisableHTTP2 {
+		return []string{"http/1.1"}
+	}
+	return []string{"h2", "http/1.1"}
+}
+
+func (l *listenerTLS) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: config.TLSVersion(l.cfg.MinVersion),
+		NextProtos: l.nextProtos(),
+		ClientAuth: clientAuthModes[l.cfg.ClientAuth],
+	}
+
+	// El primer certificado es el que se usa cuando el SNI no coincide con ninguno
+	pairs := l.cfg.Certificates
+	if l.cfg.CertFile != "" {
+		pairs = append([]config.CertificateConfig{{CertFile: l.cfg.CertFile, KeyFile: l.cfg.KeyFile}}, pairs...)
+	}
+	for _, pair := range pairs {
+		cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading certificate %s: %w", pair.CertFile, err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+
+	if l.cfg.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(l.cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client CA bundle %s", l.cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// Mayor fecha de modificación entre los archivos configurados
+func (l *listenerTLS) filesModTime() (time.Time, error) {
+	files := []string{l.cfg.CertFile, l.cfg.KeyFile, l.cfg.ClientCAFile}
+	for _, pair := range l.cfg.Certificates {
+		files = append(files, pair.CertFile, pair.KeyFile)
+	}
+
+	var latest time.Time
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Reconstruir la configuración si algún archivo cambió; si la carga falla
+// (por ejemplo, un certificado a medio escribir) se sigue usando la anterior
+func (l *listenerTLS) reloadIfChanged() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if time.Since(l.checkedAt) < certReloadCheckInterval {
+		return
+	}
+	l.checkedAt = time.Now()
+
+	modTime, err := l.filesModTime()
+	if err != nil {
+		fmt.Printf("⚠️  Listener TLS reload error: %v\n", err)
+		return
+	}
+	if !modTime.After(l.modTime) {
+		return
+	}
+
+	tlsConfig, err := l.build()
+	if err != nil {
+		fmt.Printf("⚠️  Listener TLS reload error, keeping previous certificates: %v\n", err)
+		return
+	}
+	l.modTime = modTime
+	l.current.Store(tlsConfig)
+
+	fmt.Println("🔐 Listener TLS certificates reloaded")
+}
+
+// Redirigir las requests HTTP a la misma URL en el listener HTTPS
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		host = strings.Trim(host, "[]")
+		if host == "" {
+			http.Error(w, "Host header is required", http.StatusBadRequest)
+			return
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
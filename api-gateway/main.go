@@ -4,15 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"api-gateway/config"
-	"api-gateway/health"
+	"api-gateway/metrics"
 	"api-gateway/proxy"
+	"api-gateway/storage"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -26,10 +30,19 @@ type GatewayResponse struct {
 }
 
 type APIGateway struct {
-	config        *config.Config
-	echo          *echo.Echo
-	proxyHandler  *proxy.Handler
-	healthChecker *health.Checker
+	configPath string
+	echo       *echo.Echo
+	store      storage.Store
+
+	// Listener HTTPS y redirect HTTP -> HTTPS (gateway.tls); requieren reiniciar para cambiar
+	listenerTLS    *listenerTLS
+	redirectServer *http.Server
+
+	// Runtime activo; se reemplaza completo en cada recarga de configuración
+	runtime     atomic.Pointer[gatewayRuntime]
+	reloadMutex sync.Mutex
+	version     uint64
+	lastReload  reloadStatus
 }
 
 func NewAPIGateway(configPath string) (*APIGateway, error) {
@@ -43,21 +56,50 @@ func NewAPIGateway(configPath string) (*APIGateway, error) {
 	e := echo.New()
 
 	// Middleware básico
+	e.Use(middleware.RequestID())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORS())
 
-	// Health checker
-	healthChecker := health.NewChecker()
-
-	// Proxy handler
-	proxyHandler := proxy.NewHandler(cfg, healthChecker)
+	// Storage compartido para cache y rate limiting
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("error creating storage: %w", err)
+	}
 
 	gateway := &APIGateway{
-		config:        cfg,
-		echo:          e,
-		proxyHandler:  proxyHandler,
-		healthChecker: healthChecker,
+		configPath: configPath,
+		echo:       e,
+		store:      store,
+	}
+
+	// Certificados del listener HTTPS
+	if cfg.Gateway.TLS.Enabled {
+		gateway.listenerTLS, err = newListenerTLS(cfg.Gateway.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("error loading listener TLS: %w", err)
+		}
+	}
+
+	// Construir rutas de servicios, load balancers y health checks
+	rt, err := gateway.buildRuntime(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	gateway.runtime.Store(rt)
+
+	// Errores con el formato configurado en gateway.error_format
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		gateway.current().proxyHandler.HandleError(err, c)
+	}
+	gateway.lastReload = reloadStatus{Success: true, Version: rt.version, Timestamp: rt.loadedAt}
+
+	// Exponer el estado de circuit breakers, load balancers y health checks en Prometheus
+	collector := proxy.NewStateCollector(func() *proxy.Handler {
+		return gateway.current().proxyHandler
+	})
+	if err := metrics.Registry.Register(collector); err != nil {
+		return nil, fmt.Errorf("error registering metrics: %w", err)
 	}
 
 	// Configurar rutas
@@ -66,30 +108,49 @@ func NewAPIGateway(configPath string) (*APIGateway, error) {
 	return gateway, nil
 }
 
+func (gw *APIGateway) current() *gatewayRuntime {
+	return gw.runtime.Load()
+}
+
 func (gw *APIGateway) setupRoutes() {
 	// Health check del gateway
 	gw.echo.GET("/health", gw.healthCheck)
 	gw.echo.GET("/health/services", gw.servicesHealth)
-	gw.echo.GET("/metrics", gw.getMetrics)
-
-	// Configurar servicios
-	for _, service := range gw.config.Gateway.Services {
-		gw.setupServiceRoutes(service)
+	gw.echo.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+	gw.echo.GET("/metrics/json", gw.getMetrics)
+
+	// Administración; responde 403 mientras auth.enabled sea false
+	admin := gw.echo.Group("/admin", gw.adminMiddleware())
+	admin.GET("/config", gw.getConfigVersion)
+	admin.POST("/config/reload", gw.reloadConfig)
+	admin.GET("/services/:name/groups", gw.getBackendGroups)
+	admin.PUT("/services/:name/groups", gw.updateBackendGroups)
+	admin.GET("/routes", gw.getRoutes)
+	admin.GET("/routes/test", gw.testRoute)
+	admin.POST("/denylist", gw.addDenylistEntry)
+	admin.GET("/denylist/:type/:value", gw.getDenylistEntry)
+	admin.DELETE("/denylist/:type/:value", gw.removeDenylistEntry)
+
+	// Emisión de tokens para los usuarios de auth.users
+	tokens := gw.echo.Group("/auth")
+	tokens.POST("/token", gw.issueToken)
+	tokens.POST("/refresh", gw.refreshToken)
+	tokens.POST("/revoke", gw.revokeToken)
+
+	// Las rutas de servicios viven en el router del runtime activo
+	gw.echo.Any("/*", gw.dispatch)
+}
 
-		// Agregar al health checker si está habilitado
-		if service.HealthCheck.Enabled {
-			healthURL := service.BaseURL + service.HealthCheck.Endpoint
-			interval := time.Duration(service.HealthCheck.IntervalSeconds) * time.Second
-			gw.healthChecker.AddService(service.Name, healthURL, interval)
-		}
-	}
+func (gw *APIGateway) dispatch(c echo.Context) error {
+	gw.current().router.ServeHTTP(c.Response(), c.Request())
+	return nil
 }
 
-func (gw *APIGateway) setupServiceRoutes(service config.ServiceConfig) {
-	group := gw.echo.Group(service.Prefix)
+func setupServiceRoutes(router *echo.Echo, proxyHandler *proxy.Handler, service config.ServiceConfig) {
+	group := router.Group(service.Prefix)
 
 	// Aplicar middlewares del proxy handler
-	gw.proxyHandler.ApplyMiddlewares(group, service)
+	proxyHandler.ApplyMiddlewares(group, service)
 
 	// Ruta principal del proxy
 	group.Any("/*", func(c echo.Context) error {
@@ -97,17 +158,20 @@ func (gw *APIGateway) setupServiceRoutes(service config.ServiceConfig) {
 		if path == "" {
 			path = "/"
 		}
-		return gw.proxyHandler.HandleProxy(service)(c)
+		return proxyHandler.HandleProxy(service)(c)
 	})
 }
 
 func (gw *APIGateway) healthCheck(c echo.Context) error {
+	rt := gw.current()
+
 	healthData := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Format(time.RFC3339),
-		"services":  len(gw.config.Gateway.Services),
-		"version":   "1.0.0",
-		"uptime":    time.Since(startTime).String(),
+		"status":         "healthy",
+		"timestamp":      time.Now().Format(time.RFC3339),
+		"services":       len(rt.config.Gateway.Services),
+		"version":        "1.0.0",
+		"config_version": rt.version,
+		"uptime":         time.Since(startTime).String(),
 	}
 
 	response := GatewayResponse{
@@ -120,18 +184,19 @@ func (gw *APIGateway) healthCheck(c echo.Context) error {
 }
 
 func (gw *APIGateway) servicesHealth(c echo.Context) error {
+	rt := gw.current()
 	healthStatus := make(map[string]interface{})
 	allHealthy := true
 
-	for _, service := range gw.config.Gateway.Services {
+	for _, service := range rt.config.Gateway.Services {
 		if service.HealthCheck.Enabled {
-			isHealthy := gw.healthChecker.IsHealthy(service.Name)
+			isHealthy := rt.healthChecker.IsHealthy(service.Name)
 			if !isHealthy {
 				allHealthy = false
 			}
 			healthStatus[service.Name] = map[string]interface{}{
 				"healthy":    isHealthy,
-				"last_check": gw.healthChecker.GetLastCheck(service.Name),
+				"last_check": rt.healthChecker.GetLastCheck(service.Name),
 				"status":     getHealthStatus(isHealthy),
 			}
 		} else {
@@ -147,7 +212,7 @@ func (gw *APIGateway) servicesHealth(c echo.Context) error {
 		"services":       healthStatus,
 		"timestamp":      time.Now().Format(time.RFC3339),
 		"overall_status": getOverallStatus(allHealthy),
-		"total_services": len(gw.config.Gateway.Services),
+		"total_services": len(rt.config.Gateway.Services),
 	}
 
 	var errorMessage *string
@@ -166,14 +231,17 @@ func (gw *APIGateway) servicesHealth(c echo.Context) error {
 }
 
 func (gw *APIGateway) getMetrics(c echo.Context) error {
+	rt := gw.current()
+
 	// Obtener métricas del proxy handler
-	proxyMetrics := gw.proxyHandler.GetMetrics()
+	proxyMetrics := rt.proxyHandler.GetMetrics()
 
 	// Agregar métricas del gateway
 	gatewayMetrics := map[string]interface{}{
 		"uptime_seconds":  time.Since(startTime).Seconds(),
-		"total_services":  len(gw.config.Gateway.Services),
+		"total_services":  len(rt.config.Gateway.Services),
 		"gateway_version": "1.0.0",
+		"config_version":  rt.version,
 		"timestamp":       time.Now().Format(time.RFC3339),
 	}
 
@@ -193,46 +261,101 @@ func (gw *APIGateway) getMetrics(c echo.Context) error {
 }
 
 func (gw *APIGateway) Start() error {
-	// Iniciar health checker
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	gw.healthChecker.Start(ctx)
+	// Iniciar health checker del runtime inicial
+	gw.current().start()
+
+	// Recargar la configuración cuando cambie el archivo
+	if err := gw.watchConfig(ctx); err != nil {
+		log.Printf("Config watcher disabled: %v", err)
+	}
 
 	// Canal para recibir señales del sistema
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP fuerza una recarga de configuración
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
 	// Iniciar servidor en goroutine
 	go func() {
-		port := gw.config.Gateway.Port
+		rt := gw.current()
+		port := rt.config.Gateway.Port
 		if port == "" {
 			port = "8000"
 		}
 
-		fmt.Printf("🚀 API Gateway starting on port %s\n", port)
+		if gw.listenerTLS != nil {
+			fmt.Printf("🚀 API Gateway starting on port %s (HTTPS)\n", port)
+		} else {
+			fmt.Printf("🚀 API Gateway starting on port %s\n", port)
+		}
 		fmt.Println("📋 Configured services:")
-		for _, service := range gw.config.Gateway.Services {
+		for _, service := range rt.config.Gateway.Services {
 			fmt.Printf("  - %s: %s -> %s\n", service.Name, service.Prefix, service.BaseURL)
 		}
 
-		if err := gw.echo.Start(":" + port); err != nil {
+		var err error
+		if gw.listenerTLS != nil {
+			gw.echo.TLSServer.Addr = ":" + port
+			gw.echo.TLSServer.TLSConfig = gw.listenerTLS.serverConfig()
+			err = gw.echo.StartServer(gw.echo.TLSServer)
+		} else {
+			err = gw.echo.Start(":" + port)
+		}
+		if err != nil {
 			log.Printf("Server startup error: %v", err)
 		}
 	}()
 
+	// Listener HTTP que solo redirige a HTTPS
+	if redirectPort := gw.current().config.Gateway.TLS.RedirectPort; gw.listenerTLS != nil && redirectPort != "" {
+		gw.redirectServer = &http.Server{
+			Addr:              ":" + redirectPort,
+			Handler:           httpsRedirect(gw.current().config.Gateway.Port),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			fmt.Printf("↪️  Redirecting HTTP on port %s to HTTPS\n", redirectPort)
+			if err := gw.redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Redirect listener error: %v", err)
+			}
+		}()
+	}
+
 	// Esperar señal de terminación
-	<-quit
+	for waiting := true; waiting; {
+		select {
+		case <-hup:
+			fmt.Println("🔄 SIGHUP received, reloading configuration...")
+			gw.Reload()
+		case <-quit:
+			waiting = false
+		}
+	}
 	fmt.Println("\n🛑 Shutting down API Gateway...")
 
 	// Graceful shutdown
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
 
-	if err := gw.echo.Shutdown(ctx); err != nil {
+	if gw.redirectServer != nil {
+		gw.redirectServer.Shutdown(shutdownCtx)
+	}
+
+	if err := gw.echo.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("server forced to shutdown: %w", err)
 	}
 
+	gw.current().stop()
+
+	if err := gw.store.Close(); err != nil {
+		log.Printf("Storage close error: %v", err)
+	}
+
 	fmt.Println("✅ API Gateway stopped gracefully")
 	return nil
 }
@@ -253,7 +376,31 @@ func getOverallStatus(allHealthy bool) string {
 	return "degraded_performance"
 }
 
+// Subcomando "validate": revisar la configuración sin iniciar el gateway
+func runValidate(args []string) int {
+	configPath := "config/config.json"
+	if len(args) > 0 {
+		configPath = args[0]
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: %v\n", configPath, err)
+		return 1
+	}
+
+	fmt.Printf("✅ %s is valid (%d services)\n", configPath, len(cfg.Gateway.Services))
+	return 0
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		os.Exit(runHashPassword(os.Args[2:]))
+	}
+
 	configPath := "config/config.json"
 	if len(os.Args) > 1 {
 		configPath = os.Args[1]
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "api_gateway"
+
+// Registry propio para no mezclar métricas de otras librerías
+var Registry = prometheus.NewRegistry()
+
+var (
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Total de requests procesadas por servicio, ruta, método y status.",
+	}, []string{"service", "route", "method", "status"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Latencia de las requests por servicio, ruta, método y status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "route", "method", "status"})
+
+	UpstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Errores al contactar los servicios backend, por tipo.",
+	}, []string{"service", "type"})
+
+	ClientCancellations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "client_cancellations_total",
+		Help:      "Requests abortadas por el cliente antes de completar la respuesta.",
+	}, []string{"service"})
+
+	UpstreamAttempts = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_attempts",
+		Help:      "Intentos hacia el servicio por request, incluyendo reintentos.",
+		Buckets:   []float64{1, 2, 3, 4, 5},
+	}, []string{"service"})
+
+	UpstreamRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Reintentos hacia el servicio por motivo (connection, timeout, status_<code>).",
+	}, []string{"service", "reason"})
+
+	RetryBudgetExhausted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retry_budget_exhausted_total",
+		Help:      "Reintentos descartados por falta de budget.",
+	}, []string{"service"})
+
+	HedgeRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hedge_requests_total",
+		Help:      "Requests de hedging enviadas a un segundo backend.",
+	}, []string{"service"})
+
+	HedgeWins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hedge_wins_total",
+		Help:      "Requests respondidas por el hedge antes que por la request original.",
+	}, []string{"service"})
+
+	HedgeCapped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hedge_capped_total",
+		Help:      "Hedges no enviados por superar max_percent.",
+	}, []string{"service"})
+
+	BackendGroupRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backend_group_requests_total",
+		Help:      "Requests por grupo de backends (canary) y resultado (success, error).",
+	}, []string{"service", "group", "result"})
+
+	MirrorRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mirror_requests_total",
+		Help:      "Requests copiadas al backend shadow por resultado (match, status_mismatch, shadow_error).",
+	}, []string{"service", "result"})
+
+	MirrorLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mirror_latency_seconds",
+		Help:      "Latencia de las requests copiadas al shadow y de su request principal (target: primary, shadow).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "target"})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rechazadas por el rate limiter.",
+	}, []string{"service"})
+
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Consultas al cache de respuestas por resultado (hit, miss).",
+	}, []string{"service", "result"})
+
+	WebSocketConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections_active",
+		Help:      "Conexiones WebSocket abiertas por servicio.",
+	}, []string{"service"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestsTotal,
+		RequestDuration,
+		UpstreamErrors,
+		ClientCancellations,
+		UpstreamAttempts,
+		UpstreamRetries,
+		RetryBudgetExhausted,
+		HedgeRequests,
+		HedgeWins,
+		HedgeCapped,
+		BackendGroupRequests,
+		MirrorRequests,
+		MirrorLatency,
+		RateLimitRejections,
+		CacheRequests,
+		WebSocketConnections,
+	)
+}
+
+// Handler HTTP con el formato de exposición de Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -1,35 +1,113 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"api-gateway/config"
+	"api-gateway/storage"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/labstack/echo/v4"
 )
 
 type AuthMiddleware struct {
-	config *config.AuthConfig
+	config    *config.AuthConfig
+	providers map[string]*authProvider
+	users     UserSource // nil si auth.users no está configurado
+	denylist  *Denylist  // nil si auth.denylist no está habilitada
+	store     storage.Store
 }
 
 type Claims struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	TokenUse  string `json:"token_use,omitempty"` // "refresh" en los refresh tokens del gateway
+	SessionID string `json:"sid,omitempty"`       // sesión de los tokens emitidos por /auth/token
 	jwt.RegisteredClaims
 }
 
-func NewAuthMiddleware(config *config.AuthConfig) *AuthMiddleware {
+// Claims que se exigen a los tokens de un servicio
+type claimsPolicy struct {
+	issuer    string
+	audience  []string
+	clockSkew time.Duration
+}
+
+// El store guarda el cache de los proveedores de introspección y los refresh tokens revocados
+func NewAuthMiddleware(config *config.AuthConfig, store storage.Store) (*AuthMiddleware, error) {
+	providers, err := newAuthProviders(config, store)
+	if err != nil {
+		return nil, err
+	}
+	users, err := newUserSource(config.Users)
+	if err != nil {
+		return nil, fmt.Errorf("error loading users: %w", err)
+	}
 	return &AuthMiddleware{
-		config: config,
+		config:    config,
+		providers: providers,
+		users:     users,
+		denylist:  newDenylist(config.Denylist, store),
+		store:     store,
+	}, nil
+}
+
+// Denylist de tokens; nil si auth.denylist no está habilitada
+func (am *AuthMiddleware) Denylist() *Denylist {
+	return am.denylist
+}
+
+// Iniciar el refresco de los JWKS; se detiene al cancelar el contexto
+func (am *AuthMiddleware) Start(ctx context.Context) {
+	for _, provider := range am.providers {
+		provider.Start(ctx)
 	}
 }
 
+// Validación con el proveedor local y los claims de auth (issuer, audience, clock_skew_seconds)
 func (am *AuthMiddleware) JWTMiddleware() echo.MiddlewareFunc {
+	return am.ServiceJWTMiddleware(config.ServiceAuthConfig{})
+}
+
+// Validación con el proveedor del servicio; los claims que el servicio no
+// define usan los del proveedor
+func (am *AuthMiddleware) ServiceJWTMiddleware(service config.ServiceAuthConfig) echo.MiddlewareFunc {
+	name := service.Provider
+	if name == "" {
+		name = "local"
+	}
+	provider, ok := am.providers[name]
+	if !ok {
+		// La validación de config lo impide; por las dudas, no dejar pasar a nadie
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				return NewGatewayError(http.StatusInternalServerError, LayerAuth, CodeInternal, fmt.Sprintf("Unknown auth provider: %s", name))
+			}
+		}
+	}
+	return am.jwtMiddleware(provider, servicePolicy(provider.policy, service))
+}
+
+func servicePolicy(policy claimsPolicy, service config.ServiceAuthConfig) claimsPolicy {
+	if service.Issuer != "" {
+		policy.issuer = service.Issuer
+	}
+	if len(service.Audience) > 0 {
+		policy.audience = service.Audience
+	}
+	if service.ClockSkewSeconds > 0 {
+		policy.clockSkew = time.Duration(service.ClockSkewSeconds) * time.Second
+	}
+	return policy
+}
+
+func (am *AuthMiddleware) jwtMiddleware(provider AuthProvider, policy claimsPolicy) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// Si la autenticación está deshabilitada, continuar
@@ -40,24 +118,51 @@ func (am *AuthMiddleware) JWTMiddleware() echo.MiddlewareFunc {
 			// Obtener token del header Authorization
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Authorization header required")
+				return NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeAuthRequired, "Authorization header required")
 			}
 
 			// Verificar formato Bearer
 			if !strings.HasPrefix(authHeader, "Bearer ") {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid authorization format")
+				return NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidToken, "Invalid authorization format")
 			}
 
 			// Extraer token
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 			if tokenString == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Token is required")
+				return NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeAuthRequired, "Token is required")
 			}
 
 			// Validar token
-			claims, err := am.validateToken(tokenString)
+			claims, err := provider.Authenticate(c.Request().Context(), tokenString)
+			if errors.Is(err, ErrAuthUnavailable) {
+				return NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "Authentication provider unavailable").WithCause(err)
+			}
+			if err == nil {
+				err = policy.verify(claims, time.Now())
+			}
+			if err == nil && claims.TokenUse == refreshTokenUse {
+				err = fmt.Errorf("refresh tokens are not accepted as access tokens")
+			}
 			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("Invalid token: %v", err))
+				return NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidToken, "Invalid token").WithCause(err)
+			}
+
+			// Tokens revocados antes de su exp
+			if am.denylist != nil {
+				reason, err := am.denylist.Check(c.Request().Context(), claims)
+				if err != nil && !am.config.Denylist.FailOpen {
+					return NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "Token denylist unavailable").WithCause(err)
+				}
+				if err != nil {
+					fmt.Printf("⚠️  Denylist store error: %v\n", err)
+				}
+				if reason != "" {
+					return NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeTokenRevoked, "Token has been revoked").WithCause(errors.New(reason))
+				}
+			}
+			// Los tokens de otros emisores identifican al usuario con sub
+			if claims.UserID == "" {
+				claims.UserID = claims.Subject
 			}
 
 			// Almacenar claims en el contexto
@@ -87,12 +192,12 @@ func (am *AuthMiddleware) APIKeyMiddleware() echo.MiddlewareFunc {
 			}
 
 			if apiKey == "" {
-				return echo.NewHTTPError(http.StatusUnauthorized, "API Key required")
+				return NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeAuthRequired, "API Key required")
 			}
 
 			// Validar API Key
 			if !am.validateAPIKey(apiKey) {
-				return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API Key")
+				return NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidAPIKey, "Invalid API Key")
 			}
 
 			// Almacenar información en el contexto
@@ -104,28 +209,32 @@ func (am *AuthMiddleware) APIKeyMiddleware() echo.MiddlewareFunc {
 	}
 }
 
-func (am *AuthMiddleware) validateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Verificar método de firma
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(am.config.JWTSecret), nil
-	})
-
-	if err != nil {
-		return nil, err
+func (p claimsPolicy) verify(claims *Claims, now time.Time) error {
+	// Verificar expiración
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Time.Add(p.clockSkew)) {
+		return fmt.Errorf("token has expired")
+	}
+	if claims.NotBefore != nil && now.Add(p.clockSkew).Before(claims.NotBefore.Time) {
+		return fmt.Errorf("token is not valid yet")
 	}
+	if p.issuer != "" && claims.Issuer != p.issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if len(p.audience) > 0 && !containsAny(claims.Audience, p.audience) {
+		return fmt.Errorf("token audience %v does not include %v", []string(claims.Audience), p.audience)
+	}
+	return nil
+}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		// Verificar expiración
-		if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(time.Now()) {
-			return nil, fmt.Errorf("token has expired")
+func containsAny(values, wanted []string) bool {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
 		}
-		return claims, nil
 	}
-
-	return nil, fmt.Errorf("invalid token claims")
+	return false
 }
 
 func (am *AuthMiddleware) validateAPIKey(apiKey string) bool {
@@ -143,20 +252,8 @@ func (am *AuthMiddleware) validateAPIKey(apiKey string) bool {
 
 // Generar token JWT para testing
 func (am *AuthMiddleware) GenerateToken(userID, username, role string) (string, error) {
-	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(am.config.TokenExpiry) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "api-gateway",
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(am.config.JWTSecret))
+	user := &User{ID: userID, Username: username, Role: role}
+	return am.sign(am.userClaims(user, newTokenID(), time.Now(), time.Duration(am.config.TokenExpiry)*time.Hour))
 }
 
 // Middleware para roles específicos
@@ -169,11 +266,11 @@ func (am *AuthMiddleware) RequireRole(requiredRole string) echo.MiddlewareFunc {
 
 			role, ok := c.Get("role").(string)
 			if !ok {
-				return echo.NewHTTPError(http.StatusForbidden, "Role information not found")
+				return NewGatewayError(http.StatusForbidden, LayerAuth, CodeForbidden, "Role information not found")
 			}
 
 			if role != requiredRole && role != "admin" {
-				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("Required role: %s", requiredRole))
+				return NewGatewayError(http.StatusForbidden, LayerAuth, CodeForbidden, fmt.Sprintf("Required role: %s", requiredRole))
 			}
 
 			return next(c)
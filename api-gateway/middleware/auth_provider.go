@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/storage"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// El proveedor no pudo verificar el token (discovery, JWKS o introspección
+// caídos); se responde 503 en lugar de 401
+var ErrAuthUnavailable = errors.New("auth provider unavailable")
+
+// Proveedor de autenticación: verifica el token y devuelve sus claims.
+// Expiración, issuer y audience los verifica el middleware con la política
+// del servicio.
+type AuthProvider interface {
+	Authenticate(ctx context.Context, token string) (*Claims, error)
+	// Iniciar tareas en segundo plano; se detienen al cancelar el contexto
+	Start(ctx context.Context)
+}
+
+// Proveedor con los claims que exige por defecto
+type authProvider struct {
+	AuthProvider
+	policy claimsPolicy
+}
+
+// JWT firmados: HS256 con jwt_secret y asimétricos con las claves del keySet
+type jwtProvider struct {
+	algorithms []string
+	secret     string
+	keys       *keySet
+}
+
+func (p *jwtProvider) Start(ctx context.Context) {
+	p.keys.start(ctx)
+}
+
+func (p *jwtProvider) Authenticate(ctx context.Context, tokenString string) (*Claims, error) {
+	// Solo los algoritmos configurados; exp y nbf se verifican con la tolerancia del servicio
+	parser := jwt.NewParser(jwt.WithValidMethods(p.algorithms), jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		// Verificar método de firma
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return []byte(p.secret), nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.lookup(kid, token.Method.Alg())
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		return claims, nil
+	}
+
+	return nil, fmt.Errorf("invalid token claims")
+}
+
+// Proveedores por nombre: "local" con los campos de auth más los de auth.providers
+func newAuthProviders(cfg *config.AuthConfig, store storage.Store) (map[string]*authProvider, error) {
+	keys, err := newKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	providers := map[string]*authProvider{
+		"local": {
+			AuthProvider: &jwtProvider{algorithms: cfg.Algorithms, secret: cfg.JWTSecret, keys: keys},
+			policy:       newClaimsPolicy(cfg.Issuer, cfg.Audience, cfg.ClockSkewSeconds),
+		},
+	}
+
+	for _, providerConfig := range cfg.Providers {
+		var provider AuthProvider
+		switch providerConfig.Type {
+		case "oidc":
+			discovery := newOIDCDiscovery(providerConfig.Issuer, providerConfig.Timeout)
+			provider = &jwtProvider{
+				algorithms: providerConfig.Algorithms,
+				keys:       newDiscoveredKeySet(discovery, providerConfig.RefreshSeconds, providerConfig.Timeout),
+			}
+		case "introspection":
+			provider = newIntrospectionProvider(providerConfig, store)
+		default:
+			return nil, fmt.Errorf("unknown auth provider type %q", providerConfig.Type)
+		}
+
+		// La respuesta de introspección puede no incluir iss: el issuer solo se exige en oidc
+		policy := newClaimsPolicy(providerConfig.Issuer, providerConfig.Audience, providerConfig.ClockSkewSeconds)
+		if providerConfig.Type == "introspection" {
+			policy.issuer = ""
+		}
+		providers[providerConfig.Name] = &authProvider{AuthProvider: provider, policy: policy}
+	}
+	return providers, nil
+}
+
+func newClaimsPolicy(issuer string, audience []string, clockSkewSeconds int) claimsPolicy {
+	return claimsPolicy{
+		issuer:    issuer,
+		audience:  audience,
+		clockSkew: time.Duration(clockSkewSeconds) * time.Second,
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/storage"
+	"api-gateway/testutil"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID     = "gateway"
+	testClientSecret = "introspection-check"
+)
+
+// Proveedor de identidad de prueba: discovery, JWKS e introspección
+type identityProvider struct {
+	key            *rsa.PrivateKey
+	issuer         string
+	mutex          sync.Mutex
+	tokens         map[string]time.Time // token opaco -> exp
+	introspections atomic.Int32
+	down           atomic.Bool
+}
+
+func (p *identityProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.issuer,
+			"jwks_uri":               p.issuer + "/jwks",
+			"introspection_endpoint": p.issuer + "/introspect",
+		})
+
+	case "/jwks":
+		public := p.key.PublicKey
+		json.NewEncoder(w).Encode(map[string][]map[string]string{"keys": {{
+			"kty": "RSA", "kid": "idp-1", "use": "sig", "alg": "RS256",
+			"n": encodeBase64URL(public.N.Bytes()), "e": encodeBase64URL(big.NewInt(int64(public.E)).Bytes()),
+		}}})
+
+	case "/introspect":
+		p.introspections.Add(1)
+		if p.down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != testClientID || secret != testClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		p.mutex.Lock()
+		exp, known := p.tokens[r.FormValue("token")]
+		p.mutex.Unlock()
+		if !known || time.Now().After(exp) {
+			json.NewEncoder(w).Encode(map[string]bool{"active": false})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"active": true, "sub": "u-77", "username": "lucia", "scope": "pagos",
+			"aud": "pagos", "exp": exp.Unix(),
+		})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (p *identityProvider) issue(token string, ttl time.Duration) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.tokens[token] = time.Now().Add(ttl)
+	return token
+}
+
+// JWT firmado por el proveedor
+func (p *identityProvider) jwt(t *testing.T, issuer string) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, &Claims{
+		Username: "maria",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "u-42",
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{"polizas"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	token.Header["kid"] = "idp-1"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// Proveedores por servicio: JWT local, OIDC con discovery y JWKS, e
+// introspección de tokens opacos con cache acotado por el exp del token.
+// Los pasos comparten el proveedor y su contador, así que corren en orden.
+func TestServiceAuthProviders(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &identityProvider{key: key, tokens: make(map[string]time.Time)}
+	server := httptest.NewServer(idp)
+	defer server.Close()
+	idp.issuer = server.URL
+
+	cfg := testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {
+    "services": [
+      {"name": "leads", "prefix": "/leads", "base_url": "http://127.0.0.1:1"},
+      {"name": "polizas", "prefix": "/polizas", "base_url": "http://127.0.0.1:1", "auth": {"provider": "idp"}},
+      {"name": "reclamos", "prefix": "/reclamos", "base_url": "http://127.0.0.1:1", "auth": {"provider": "caido"}},
+      {"name": "pagos", "prefix": "/pagos", "base_url": "http://127.0.0.1:1", "auth": {"provider": "opaco"}}
+    ]
+  },
+  "auth": {
+    "enabled": true,
+    "jwt_secret": "providers-check",
+    "token_expiry_hours": 1,
+    "providers": [
+      {"name": "idp", "type": "oidc", "issuer": %[1]q, "audience": ["polizas"]},
+      {"name": "caido", "type": "oidc", "issuer": "http://127.0.0.1:1", "timeout": 1},
+      {"name": "opaco", "type": "introspection", "issuer": %[1]q, "audience": ["pagos"],
+       "client_id": %[2]q, "client_secret": %[3]q, "cache_seconds": 300}
+    ]
+  }
+}`, server.URL, testClientID, testClientSecret))
+
+	auth, err := NewAuthMiddleware(&cfg.Auth, storage.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auth.Start(ctx)
+
+	e := newAuthTestRouter()
+	for _, service := range cfg.Gateway.Services {
+		e.GET(service.Prefix, authTestOK, auth.ServiceJWTMiddleware(service.Auth))
+	}
+
+	localToken, err := auth.GenerateToken("u-1", "admin", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	active := idp.issue("opaque-active", time.Hour)
+	// El exp de la introspección se trunca a segundos: 2s dejan al menos 1s de vigencia
+	short := idp.issue("opaque-short", 2*time.Second)
+
+	type step struct {
+		name           string
+		path           string
+		token          string
+		status         int
+		introspections int32 // llamadas al endpoint de introspección esperadas tras el paso; -1 = no verificar
+		before         func()
+	}
+	steps := []step{
+		{name: "local accepts the gateway HS256 JWT", path: "/leads", token: localToken, status: http.StatusOK, introspections: -1},
+		{name: "local rejects the OIDC provider JWT", path: "/leads", token: idp.jwt(t, server.URL), status: http.StatusUnauthorized, introspections: -1},
+		{name: "oidc accepts a JWT signed with the discovered keys", path: "/polizas", token: idp.jwt(t, server.URL), status: http.StatusOK, introspections: -1},
+		{name: "oidc rejects a JWT from another issuer", path: "/polizas", token: idp.jwt(t, "https://otro.example.com"), status: http.StatusUnauthorized, introspections: -1},
+		{name: "oidc rejects the gateway HS256 JWT", path: "/polizas", token: localToken, status: http.StatusUnauthorized, introspections: -1},
+		{name: "oidc without a reachable discovery is unavailable", path: "/reclamos", token: idp.jwt(t, "http://127.0.0.1:1"), status: http.StatusServiceUnavailable, introspections: -1},
+
+		{name: "introspection accepts an active token", path: "/pagos", token: active, status: http.StatusOK, introspections: 1},
+		{name: "introspection serves the active token from the cache", path: "/pagos", token: active, status: http.StatusOK, introspections: 1},
+		{name: "introspection rejects an inactive token", path: "/pagos", token: "opaque-desconocido", status: http.StatusUnauthorized, introspections: 2},
+		{name: "introspection does not cache inactive tokens", path: "/pagos", token: "opaque-desconocido", status: http.StatusUnauthorized, introspections: 3},
+		{name: "introspection accepts a token expiring in 2s", path: "/pagos", token: short, status: http.StatusOK, introspections: 4},
+		{name: "introspection cache does not outlive exp", path: "/pagos", token: short, status: http.StatusUnauthorized, introspections: 5,
+			before: func() { time.Sleep(2500 * time.Millisecond) }},
+		{name: "introspection serves cached tokens while the provider is down", path: "/pagos", token: active, status: http.StatusOK, introspections: 5,
+			before: func() { idp.down.Store(true) }},
+		{name: "introspection of a new token with the provider down is unavailable", path: "/pagos", token: idp.issue("opaque-nuevo", time.Hour), status: http.StatusServiceUnavailable, introspections: 6},
+	}
+
+	for _, s := range steps {
+		if s.before != nil {
+			s.before()
+		}
+		rec := authTestRequest(e, s.path, s.token)
+		if rec.Code != s.status {
+			t.Errorf("%s: status = %d, want %d (%s)", s.name, rec.Code, s.status, rec.Body.String())
+		}
+		if calls := idp.introspections.Load(); s.introspections >= 0 && calls != s.introspections {
+			t.Errorf("%s: %d introspection calls, want %d", s.name, calls, s.introspections)
+		}
+	}
+}
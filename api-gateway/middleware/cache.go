@@ -0,0 +1,293 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/metrics"
+	"api-gateway/storage"
+)
+
+// Respuesta almacenada en cache
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+	ExpiresAt  time.Time
+}
+
+// Headers de la respuesta que se guardan junto al body
+var cachedResponseHeaders = []string{
+	"Content-Type",
+	"Cache-Control",
+	"ETag",
+	"Last-Modified",
+	"Expires",
+	"Vary",
+}
+
+// Cache de respuestas GET por servicio
+type ResponseCache struct {
+	name   string
+	config config.CacheConfig
+	store  storage.Store
+
+	hits   uint64
+	misses uint64
+}
+
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+func NewResponseCache(name string, config config.CacheConfig, store storage.Store) *ResponseCache {
+	return &ResponseCache{
+		name:   name,
+		config: config,
+		store:  store,
+	}
+}
+
+// Verificar si la request puede resolverse desde cache
+func (rc *ResponseCache) IsCacheable(req *http.Request) bool {
+	if !rc.config.Enabled || req.Method != http.MethodGet {
+		return false
+	}
+
+	// El cliente puede pedir explícitamente saltarse el cache
+	directives := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, noStore := directives["no-store"]; noStore {
+		return false
+	}
+	if _, noCache := directives["no-cache"]; noCache {
+		return false
+	}
+
+	return true
+}
+
+func (rc *ResponseCache) Get(req *http.Request) (*CachedResponse, bool) {
+	entry, err := rc.lookup(req)
+	if err != nil {
+		fmt.Printf("⚠️  Cache store error [%s]: %v\n", rc.name, err)
+	}
+	if entry == nil || time.Now().After(entry.ExpiresAt) {
+		atomic.AddUint64(&rc.misses, 1)
+		metrics.CacheRequests.WithLabelValues(rc.name, "miss").Inc()
+		return nil, false
+	}
+
+	atomic.AddUint64(&rc.hits, 1)
+	metrics.CacheRequests.WithLabelValues(rc.name, "hit").Inc()
+	return entry, true
+}
+
+func (rc *ResponseCache) lookup(req *http.Request) (*CachedResponse, error) {
+	ctx := req.Context()
+	baseKey := rc.baseKey(req)
+
+	// Si el upstream respondió con Vary, la key depende de esos headers
+	var varyHeaders []string
+	if raw, found, err := rc.store.Get(ctx, rc.storeKey("vary", baseKey)); err != nil {
+		return nil, err
+	} else if found {
+		if err := json.Unmarshal(raw, &varyHeaders); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, found, err := rc.store.Get(ctx, rc.storeKey("entry", variantKey(baseKey, varyHeaders, req)))
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var entry CachedResponse
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Guardar una respuesta respetando Cache-Control y Vary del upstream
+func (rc *ResponseCache) Set(req *http.Request, upstreamHeader http.Header, statusCode int, header http.Header, body []byte) {
+	if statusCode < 200 || statusCode >= 300 {
+		return
+	}
+
+	ttl, ok := rc.ttlFor(req, upstreamHeader)
+	if !ok {
+		return
+	}
+
+	varyHeaders := parseVary(upstreamHeader.Get("Vary"))
+	for _, name := range varyHeaders {
+		if name == "*" {
+			return
+		}
+	}
+
+	stored := make(http.Header)
+	for _, name := range cachedResponseHeaders {
+		if value := header.Get(name); value != "" {
+			stored.Set(name, value)
+		}
+	}
+
+	now := time.Now()
+	raw, err := json.Marshal(CachedResponse{
+		StatusCode: statusCode,
+		Header:     stored,
+		Body:       body,
+		StoredAt:   now,
+		ExpiresAt:  now.Add(ttl),
+	})
+	if err != nil {
+		fmt.Printf("⚠️  Cache encode error [%s]: %v\n", rc.name, err)
+		return
+	}
+
+	ctx := req.Context()
+	baseKey := rc.baseKey(req)
+	varyKey := rc.storeKey("vary", baseKey)
+	if len(varyHeaders) > 0 {
+		varyRaw, _ := json.Marshal(varyHeaders)
+		err = rc.store.Set(ctx, varyKey, varyRaw, ttl)
+	} else {
+		err = rc.store.Delete(ctx, varyKey)
+	}
+	if err == nil {
+		err = rc.store.Set(ctx, rc.storeKey("entry", variantKey(baseKey, varyHeaders, req)), raw, ttl)
+	}
+	if err != nil {
+		fmt.Printf("⚠️  Cache store error [%s]: %v\n", rc.name, err)
+	}
+}
+
+func (rc *ResponseCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&rc.hits),
+		Misses: atomic.LoadUint64(&rc.misses),
+	}
+}
+
+// Key en el store: servicio + hash de la key lógica
+func (rc *ResponseCache) storeKey(kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "cache:" + rc.name + ":" + kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// Determinar el TTL a partir de la configuración y del Cache-Control del upstream
+func (rc *ResponseCache) ttlFor(req *http.Request, upstreamHeader http.Header) (time.Duration, bool) {
+	ttl := time.Duration(rc.config.TTL) * time.Second
+	directives := parseCacheControl(upstreamHeader.Get("Cache-Control"))
+
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, exists := directives[directive]; exists {
+			return 0, false
+		}
+	}
+
+	// Requests autenticadas solo se cachean si el upstream lo permite
+	// o si el header Authorization forma parte de la key
+	if req.Header.Get("Authorization") != "" && !rc.keyIncludes("Authorization") {
+		_, public := directives["public"]
+		_, shared := directives["s-maxage"]
+		if !public && !shared {
+			return 0, false
+		}
+	}
+
+	// s-maxage tiene prioridad sobre max-age para caches compartidos
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, exists := directives[directive]; exists {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			ttl = time.Duration(seconds) * time.Second
+			break
+		}
+	}
+
+	return ttl, ttl > 0
+}
+
+func (rc *ResponseCache) keyIncludes(header string) bool {
+	for _, name := range rc.config.KeyHeaders {
+		if strings.EqualFold(name, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// Key base: método, path, query normalizada y headers configurados
+func (rc *ResponseCache) baseKey(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteString(" ")
+	b.WriteString(req.URL.Path)
+	b.WriteString("?")
+	b.WriteString(req.URL.Query().Encode())
+
+	for _, name := range rc.config.KeyHeaders {
+		b.WriteString("|")
+		b.WriteString(strings.ToLower(name))
+		b.WriteString("=")
+		b.WriteString(strings.Join(req.Header.Values(name), ","))
+	}
+
+	return b.String()
+}
+
+func variantKey(baseKey string, varyHeaders []string, req *http.Request) string {
+	if len(varyHeaders) == 0 {
+		return baseKey
+	}
+
+	var b strings.Builder
+	b.WriteString(baseKey)
+	for _, name := range varyHeaders {
+		b.WriteString("|vary:")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strings.Join(req.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), "\"")
+	}
+	return directives
+}
+
+func parseVary(value string) []string {
+	var headers []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			headers = append(headers, http.CanonicalHeaderKey(part))
+		}
+	}
+	sort.Strings(headers)
+	return headers
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -64,6 +65,9 @@ func (c *Counts) Clear() {
 	c.ConsecutiveFailures = 0
 }
 
+// La request terminó porque el cliente se desconectó; no cuenta como éxito ni como fallo
+var ErrClientCanceled = errors.New("request canceled by client")
+
 // Configuración del Circuit Breaker
 type CircuitBreakerSettings struct {
 	Name         string
@@ -125,6 +129,10 @@ func (cb *CircuitBreaker) Execute(req func() (interface{}, error)) (interface{},
 	}()
 	
 	result, err := req()
+	if errors.Is(err, ErrClientCanceled) {
+		cb.releaseRequest(generation)
+		return result, err
+	}
 	cb.afterRequest(generation, err == nil)
 	return result, err
 }
@@ -164,6 +172,17 @@ func (cb *CircuitBreaker) afterRequest(before uint64, success bool) {
 	}
 }
 
+// Descontar una request que no llegó a tener resultado
+func (cb *CircuitBreaker) releaseRequest(before uint64) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	_, generation := cb.currentState(time.Now())
+	if generation == before && cb.counts.Requests > 0 {
+		cb.counts.Requests--
+	}
+}
+
 func (cb *CircuitBreaker) onSuccess(state State, now time.Time) {
 	cb.counts.OnSuccess()
 	
@@ -271,16 +290,18 @@ func CircuitBreakerMiddleware(serviceName string) echo.MiddlewareFunc {
 				return nil, next(c)
 			})
 			
+			if errors.Is(err, ErrClientCanceled) {
+				return err
+			}
 			if err != nil {
 				if cb.State() == StateOpen {
-					return echo.NewHTTPError(http.StatusServiceUnavailable, map[string]interface{}{
-						"error": "Service temporarily unavailable",
+					return NewGatewayError(http.StatusServiceUnavailable, LayerCircuitBreaker, CodeCircuitOpen, "Service temporarily unavailable").WithDetails(map[string]interface{}{
 						"reason": "Circuit breaker is open",
 						"service": serviceName,
 						"retry_after": cb.timeout.Seconds(),
 					})
 				}
-				return echo.NewHTTPError(http.StatusBadGateway, "Service error")
+				return NewGatewayError(http.StatusBadGateway, LayerUpstream, CodeUpstreamUnavailable, "Service error").WithCause(err)
 			}
 			
 			return result.(error)
@@ -322,6 +343,14 @@ func (cbm *CircuitBreakerManager) GetBreaker(serviceName string) (*CircuitBreake
 	return cb, exists
 }
 
+// Reutilizar un breaker existente (p.ej. el del runtime anterior a una recarga)
+func (cbm *CircuitBreakerManager) SetBreaker(serviceName string, cb *CircuitBreaker) {
+	cbm.mutex.Lock()
+	defer cbm.mutex.Unlock()
+
+	cbm.breakers[serviceName] = cb
+}
+
 func (cbm *CircuitBreakerManager) GetAllBreakers() map[string]*CircuitBreaker {
 	cbm.mutex.RLock()
 	defer cbm.mutex.RUnlock()
@@ -343,9 +372,9 @@ func (cbm *CircuitBreakerManager) Middleware(serviceName string) echo.Middleware
 			return counts.ConsecutiveFailures >= 5 || 
 				   (counts.Requests >= 10 && float64(counts.TotalFailures)/float64(counts.Requests) >= 0.5)
 		},
+		// Se llama con el mutex del breaker tomado: no consultar Counts() acá
 		OnStateChange: func(name string, from State, to State) {
-			fmt.Printf("🔌 Circuit Breaker [%s] state changed: %s -> %s (failures: %d, requests: %d)\n", 
-				name, from, to, cbm.breakers[name].Counts().TotalFailures, cbm.breakers[name].Counts().Requests)
+			fmt.Printf("🔌 Circuit Breaker [%s] state changed: %s -> %s\n", name, from, to)
 		},
 	}
 	
@@ -368,13 +397,15 @@ func (cbm *CircuitBreakerManager) Middleware(serviceName string) echo.Middleware
 				return nil, nil
 			})
 			
+			if errors.Is(err, ErrClientCanceled) {
+				return err
+			}
 			if err != nil {
 				state := cb.State()
 				counts := cb.Counts()
 				
 				if state == StateOpen {
-					return echo.NewHTTPError(http.StatusServiceUnavailable, map[string]interface{}{
-						"error": "Service temporarily unavailable",
+					return NewGatewayError(http.StatusServiceUnavailable, LayerCircuitBreaker, CodeCircuitOpen, "Service temporarily unavailable").WithDetails(map[string]interface{}{
 						"reason": "Circuit breaker is open",
 						"service": serviceName,
 						"state": state.String(),
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/storage"
+)
+
+// Tipos de entrada de la denylist
+const (
+	DenylistJTI     = "jti"
+	DenylistSubject = "subject"
+)
+
+// Entrada rechazada por Add; el resto de los errores son del store
+var ErrInvalidDenylistEntry = errors.New("invalid denylist entry")
+
+// Entrada de la denylist. Una entrada por jti revoca ese token; una por
+// subject revoca los tokens del usuario emitidos hasta CreatedAt, así que
+// los que obtenga después (por ejemplo, al rehabilitar la cuenta) son válidos.
+type DenylistEntry struct {
+	Type      string    `json:"type"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Tokens revocados, compartidos entre réplicas a través del store. Las
+// entradas expiran solas cuando ya no queda ningún token al que puedan aplicar.
+type Denylist struct {
+	store       storage.Store
+	maxLifetime time.Duration
+}
+
+func newDenylist(cfg config.DenylistConfig, store storage.Store) *Denylist {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Denylist{
+		store:       store,
+		maxLifetime: time.Duration(cfg.MaxTokenLifetimeSeconds) * time.Second,
+	}
+}
+
+func ValidDenylistType(entryType string) bool {
+	return entryType == DenylistJTI || entryType == DenylistSubject
+}
+
+// Agregar una entrada. Una entrada por jti puede indicar el exp del token;
+// las demás, y ninguna más allá de eso, duran la vida máxima de un token.
+func (d *Denylist) Add(ctx context.Context, entry DenylistEntry) (*DenylistEntry, error) {
+	if !ValidDenylistType(entry.Type) || entry.Value == "" {
+		return nil, fmt.Errorf("%w: type must be jti or subject and value is required", ErrInvalidDenylistEntry)
+	}
+
+	entry.CreatedAt = time.Now()
+	latest := entry.CreatedAt.Add(d.maxLifetime)
+	if entry.ExpiresAt.IsZero() || entry.Type == DenylistSubject || entry.ExpiresAt.After(latest) {
+		entry.ExpiresAt = latest
+	}
+	ttl := time.Until(entry.ExpiresAt)
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: expires_at is in the past", ErrInvalidDenylistEntry)
+	}
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.store.Set(ctx, denylistKey(entry.Type, entry.Value), raw, ttl); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (d *Denylist) Get(ctx context.Context, entryType, value string) (*DenylistEntry, bool, error) {
+	raw, found, err := d.store.Get(ctx, denylistKey(entryType, value))
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	var entry DenylistEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, false, err
+	}
+	return &entry, true, nil
+}
+
+func (d *Denylist) Remove(ctx context.Context, entryType, value string) error {
+	return d.store.Delete(ctx, denylistKey(entryType, value))
+}
+
+// Motivo por el que el token está revocado, vacío si no lo está. También se
+// rechazan los tokens de una sesión cerrada con /auth/revoke.
+func (d *Denylist) Check(ctx context.Context, claims *Claims) (string, error) {
+	if claims.ID != "" {
+		_, found, err := d.Get(ctx, DenylistJTI, claims.ID)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return "token has been revoked", nil
+		}
+	}
+
+	subject := claims.Subject
+	if subject == "" {
+		subject = claims.UserID
+	}
+	if subject != "" {
+		entry, found, err := d.Get(ctx, DenylistSubject, subject)
+		if err != nil {
+			return "", err
+		}
+		// Sin iat no se sabe si el token es anterior a la entrada
+		if found && (claims.IssuedAt == nil || !claims.IssuedAt.Time.After(entry.CreatedAt)) {
+			return "tokens of this subject have been revoked", nil
+		}
+	}
+
+	if claims.SessionID != "" {
+		_, found, err := d.store.Get(ctx, revokedSessionKey(claims.SessionID))
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return "session has been revoked", nil
+		}
+	}
+	return "", nil
+}
+
+func denylistKey(entryType, value string) string {
+	return "auth:denylist:" + entryType + ":" + value
+}
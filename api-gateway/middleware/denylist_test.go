@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/storage"
+	"api-gateway/testutil"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// Configuración de tokens (access 1h, refresh 24h) con la denylist habilitada;
+// max_token_lifetime_seconds queda en su valor por defecto
+func denylistTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg := tokenTestConfig(t)
+	cfg.Auth.Denylist.Enabled = true
+	return cfg
+}
+
+// La entrada de un subject revocado dura lo que el refresh token, no lo que
+// el access token. miniredis adelanta el reloj de los TTL; el del gateway,
+// con el que se valida el refresh token, sigue igual.
+func TestDenylistSubjectOutlivesAccessToken(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	mr := miniredis.RunT(t)
+	store, err := storage.NewRedisStore(config.RedisConfig{Address: mr.Addr(), KeyPrefix: "test:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	cfg := denylistTestConfig(t)
+	auth, _ := newTokenTestAuth(t, cfg, store)
+	ctx := context.Background()
+
+	pair, err := auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := auth.Denylist().Add(ctx, DenylistEntry{Type: DenylistSubject, Value: "u-42"})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if lifetime := entry.ExpiresAt.Sub(entry.CreatedAt); lifetime != time.Duration(cfg.Auth.RefreshExpiry)*time.Hour {
+		t.Errorf("subject entry lasts %v, want the refresh token lifetime", lifetime)
+	}
+
+	mr.FastForward(time.Duration(cfg.Auth.TokenExpiry)*time.Hour + time.Minute)
+
+	_, err = auth.RefreshTokens(ctx, pair.RefreshToken)
+	expectGatewayCode(t, err, CodeTokenRevoked)
+}
+
+// Store que no responde, para verificar fail_open
+type failingStore struct {
+	storage.Store
+}
+
+func (failingStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+// jti de un token sin verificar la firma
+func tokenJTI(t *testing.T, token string) string {
+	t.Helper()
+
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims.ID
+}
+
+func expectRevoked(t *testing.T, e *echo.Echo, token string) {
+	t.Helper()
+
+	rec := authTestRequest(e, "/leads", token)
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusUnauthorized || body.Code != CodeTokenRevoked {
+		t.Fatalf("status = %d %s, want 401 %s", rec.Code, body.Code, CodeTokenRevoked)
+	}
+}
+
+// Revocaciones por jti y por subject hechas en una réplica valen en las demás
+// que comparten el store. Los pasos dependen de las entradas anteriores.
+func TestDenylistSharedAcrossReplicas(t *testing.T) {
+	cfg := denylistTestConfig(t)
+	store := storage.NewMemoryStore()
+	a, routerA := newTokenTestAuth(t, cfg, store)
+	_, routerB := newTokenTestAuth(t, cfg, store)
+	ctx := context.Background()
+	denylist := a.Denylist()
+
+	maria, _ := a.GenerateToken("u-42", "maria", "gestor")
+	otherMaria, _ := a.GenerateToken("u-42", "maria", "gestor")
+	jorge, _ := a.GenerateToken("u-50", "jorge", "gestor")
+
+	expectAccessStatus(t, routerA, maria, http.StatusOK)
+
+	if _, err := denylist.Add(ctx, DenylistEntry{Type: DenylistJTI, Value: tokenJTI(t, maria)}); err != nil {
+		t.Fatalf("Add(jti): %v", err)
+	}
+	expectRevoked(t, routerB, maria)
+	// Otro token del mismo usuario sigue válido
+	expectAccessStatus(t, routerB, otherMaria, http.StatusOK)
+
+	if err := denylist.Remove(ctx, DenylistJTI, tokenJTI(t, maria)); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	expectAccessStatus(t, routerA, maria, http.StatusOK)
+
+	if _, err := denylist.Add(ctx, DenylistEntry{Type: DenylistSubject, Value: "u-42", Reason: "cuenta deshabilitada"}); err != nil {
+		t.Fatalf("Add(subject): %v", err)
+	}
+	expectRevoked(t, routerA, maria)
+	expectRevoked(t, routerB, otherMaria)
+	expectAccessStatus(t, routerA, jorge, http.StatusOK)
+
+	// El iat tiene resolución de segundos: los tokens emitidos después de la
+	// entrada son válidos
+	time.Sleep(1100 * time.Millisecond)
+	fresh, _ := a.GenerateToken("u-42", "maria", "gestor")
+	expectAccessStatus(t, routerB, fresh, http.StatusOK)
+}
+
+func TestDenylistJTIExpiry(t *testing.T) {
+	auth, e := newTokenTestAuth(t, denylistTestConfig(t), storage.NewMemoryStore())
+	ctx := context.Background()
+
+	token, _ := auth.GenerateToken("u-42", "maria", "gestor")
+	jti := tokenJTI(t, token)
+	if _, err := auth.Denylist().Add(ctx, DenylistEntry{Type: DenylistJTI, Value: jti, ExpiresAt: time.Now().Add(100 * time.Millisecond)}); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(t, e, token)
+
+	time.Sleep(200 * time.Millisecond)
+	if _, found, _ := auth.Denylist().Get(ctx, DenylistJTI, jti); found {
+		t.Error("entry still present after expires_at")
+	}
+
+	// Una entrada ya vencida se rechaza
+	_, err := auth.Denylist().Add(ctx, DenylistEntry{Type: DenylistJTI, Value: jti, ExpiresAt: time.Now().Add(-time.Second)})
+	if !errors.Is(err, ErrInvalidDenylistEntry) {
+		t.Errorf("Add(past expires_at) = %v, want ErrInvalidDenylistEntry", err)
+	}
+}
+
+func TestDenylistRevokedSessions(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	auth, e := newTokenTestAuth(t, denylistTestConfig(t), storage.NewMemoryStore())
+	ctx := context.Background()
+
+	// El logout rechaza también el access token de la sesión
+	pair, err := auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAccessStatus(t, e, pair.AccessToken, http.StatusOK)
+	if err := auth.RevokeToken(ctx, pair.RefreshToken); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	expectRevoked(t, e, pair.AccessToken)
+
+	// Un subject revocado no puede renovar sus tokens
+	pair, err = auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Denylist().Add(ctx, DenylistEntry{Type: DenylistSubject, Value: "u-42"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = auth.RefreshTokens(ctx, pair.RefreshToken)
+	expectGatewayCode(t, err, CodeTokenRevoked)
+}
+
+func TestDenylistStoreUnavailable(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	tests := []struct {
+		name     string
+		failOpen bool
+		status   int
+	}{
+		{"fails closed by default", false, http.StatusServiceUnavailable},
+		{"fail_open accepts the token", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := denylistTestConfig(t)
+			cfg.Auth.Denylist.FailOpen = tt.failOpen
+			auth, e := newTokenTestAuth(t, cfg, failingStore{storage.NewMemoryStore()})
+
+			token, _ := auth.GenerateToken("u-42", "maria", "gestor")
+			expectAccessStatus(t, e, token, tt.status)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Capa del gateway donde se originó un error
+const (
+	LayerAuth           = "auth"
+	LayerRateLimit      = "rate_limit"
+	LayerCircuitBreaker = "circuit_breaker"
+	LayerUpstream       = "upstream"
+	LayerGateway        = "gateway"
+)
+
+// Códigos de error estables para los clientes; no cambiarlos una vez publicados
+const (
+	CodeAuthRequired        = "AUTH_REQUIRED"
+	CodeInvalidToken        = "AUTH_INVALID_TOKEN"
+	CodeInvalidAPIKey       = "AUTH_INVALID_API_KEY"
+	CodeInvalidCredentials  = "AUTH_INVALID_CREDENTIALS"
+	CodeTokenRevoked        = "AUTH_TOKEN_REVOKED"
+	CodeForbidden           = "AUTH_FORBIDDEN"
+	CodeAuthUnavailable     = "AUTH_PROVIDER_UNAVAILABLE"
+	CodeRateLimited         = "RATE_LIMIT_EXCEEDED"
+	CodeCircuitOpen         = "CIRCUIT_OPEN"
+	CodeNoHealthyBackend    = "NO_HEALTHY_BACKEND"
+	CodeUpstreamUnavailable = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamTimeout     = "UPSTREAM_TIMEOUT"
+	CodeUpstreamBadResponse = "UPSTREAM_BAD_RESPONSE"
+	CodeBadRequest          = "BAD_REQUEST"
+	CodeNotFound            = "NOT_FOUND"
+	CodeMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	CodeInternal            = "INTERNAL_ERROR"
+)
+
+// Error del gateway con status HTTP, código estable y capa de origen.
+// El error handler central lo convierte al formato configurado.
+type GatewayError struct {
+	Status  int
+	Code    string
+	Layer   string
+	Message string
+	Details map[string]interface{}
+	Cause   error
+}
+
+func NewGatewayError(status int, layer, code, message string) *GatewayError {
+	return &GatewayError{
+		Status:  status,
+		Code:    code,
+		Layer:   layer,
+		Message: message,
+	}
+}
+
+func (e *GatewayError) WithDetails(details map[string]interface{}) *GatewayError {
+	e.Details = details
+	return e
+}
+
+func (e *GatewayError) WithCause(err error) *GatewayError {
+	e.Cause = err
+	return e
+}
+
+func (e *GatewayError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.Cause.Error())
+	}
+	return e.Message
+}
+
+func (e *GatewayError) Unwrap() error {
+	return e.Cause
+}
+
+// Convertir cualquier error devuelto por un handler en GatewayError;
+// los de Echo (404 de rutas, 405, body inválido) se atribuyen al gateway
+func AsGatewayError(err error) *GatewayError {
+	var gwErr *GatewayError
+	if errors.As(err, &gwErr) {
+		return gwErr
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message := http.StatusText(httpErr.Code)
+		if text, ok := httpErr.Message.(string); ok {
+			message = text
+		}
+		return NewGatewayError(httpErr.Code, LayerGateway, httpErrorCode(httpErr.Code), message)
+	}
+
+	return NewGatewayError(http.StatusInternalServerError, LayerGateway, CodeInternal, "Internal server error").WithCause(err)
+}
+
+func httpErrorCode(status int) string {
+	switch {
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case status == http.StatusUnauthorized:
+		return CodeAuthRequired
+	case status == http.StatusForbidden:
+		return CodeForbidden
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status >= 500:
+		return CodeInternal
+	default:
+		return CodeBadRequest
+	}
+}
+
+// Request ID de la request: el asignado por el gateway o el que envió el cliente
+func RequestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/storage"
+)
+
+// Tokens opacos validados con el endpoint de introspección del proveedor
+// (RFC 7662). Los tokens activos se guardan en el store hasta cache_seconds,
+// nunca más allá de su exp; los inactivos no se guardan.
+type introspectionProvider struct {
+	name      string
+	cfg       config.AuthProviderConfig
+	discovery *oidcDiscovery // solo si no hay introspection_url
+	client    *http.Client
+	store     storage.Store
+}
+
+// Respuesta de introspección: active más los claims del token
+type introspectionResponse struct {
+	Active bool `json:"active"`
+	Claims
+}
+
+func newIntrospectionProvider(cfg config.AuthProviderConfig, store storage.Store) *introspectionProvider {
+	p := &introspectionProvider{
+		name:   cfg.Name,
+		cfg:    cfg,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		store:  store,
+	}
+	if cfg.IntrospectionURL == "" {
+		p.discovery = newOIDCDiscovery(cfg.Issuer, cfg.Timeout)
+	}
+	return p
+}
+
+func (p *introspectionProvider) Start(ctx context.Context) {}
+
+func (p *introspectionProvider) Authenticate(ctx context.Context, token string) (*Claims, error) {
+	key := p.storeKey(token)
+	if raw, found, err := p.store.Get(ctx, key); err != nil {
+		fmt.Printf("⚠️  Introspection cache error [%s]: %v\n", p.name, err)
+	} else if found {
+		var claims Claims
+		if err := json.Unmarshal(raw, &claims); err == nil {
+			return &claims, nil
+		}
+	}
+
+	result, err := p.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Active {
+		return nil, errors.New("token is not active")
+	}
+
+	claims := &result.Claims
+	if ttl := p.cacheTTL(claims, time.Now()); ttl > 0 {
+		raw, _ := json.Marshal(claims)
+		if err := p.store.Set(ctx, key, raw, ttl); err != nil {
+			fmt.Printf("⚠️  Introspection cache error [%s]: %v\n", p.name, err)
+		}
+	}
+	return claims, nil
+}
+
+func (p *introspectionProvider) introspect(ctx context.Context, token string) (*introspectionResponse, error) {
+	endpoint, err := p.endpoint()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthUnavailable, err)
+	}
+
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: introspection request failed: %v", ErrAuthUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: introspection endpoint returned status %d", ErrAuthUnavailable, resp.StatusCode)
+	}
+
+	var result introspectionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: invalid introspection response: %v", ErrAuthUnavailable, err)
+	}
+	return &result, nil
+}
+
+func (p *introspectionProvider) endpoint() (string, error) {
+	if p.discovery == nil {
+		return p.cfg.IntrospectionURL, nil
+	}
+	document, err := p.discovery.get()
+	if err != nil {
+		return "", err
+	}
+	if document.IntrospectionEndpoint == "" {
+		return "", fmt.Errorf("discovery document of %s has no introspection_endpoint", p.cfg.Issuer)
+	}
+	return document.IntrospectionEndpoint, nil
+}
+
+// Tiempo en cache: cache_seconds, acotado por el exp del token
+func (p *introspectionProvider) cacheTTL(claims *Claims, now time.Time) time.Duration {
+	ttl := time.Duration(p.cfg.CacheSeconds) * time.Second
+	if claims.ExpiresAt != nil {
+		if remaining := claims.ExpiresAt.Time.Sub(now); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl
+}
+
+// Key en el store: proveedor + hash del token, que nunca se guarda en claro
+func (p *introspectionProvider) storeKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "auth:introspection:" + p.name + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,344 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"api-gateway/config"
+)
+
+// Tiempo mínimo entre descargas del JWKS forzadas por un kid desconocido,
+// para que tokens con kids inventados no generen una descarga por request
+const jwksMinRefreshInterval = 30 * time.Second
+
+// Clave pública para verificar firmas
+type verificationKey struct {
+	kid string
+	alg string // alg declarado en el JWK; vacío = cualquiera compatible
+	key crypto.PublicKey
+}
+
+// Si la clave sirve para el algoritmo del token
+func (k verificationKey) supports(alg string) bool {
+	if k.alg != "" && k.alg != alg {
+		return false
+	}
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256"
+	case *ecdsa.PublicKey:
+		return alg == "ES256" && key.Curve == elliptic.P256()
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// Claves de verificación: las de archivos PEM, fijas, y las del JWKS remoto,
+// que se reemplazan en cada descarga
+type keySet struct {
+	static    []verificationKey
+	jwks      config.JWKSConfig
+	discovery *oidcDiscovery // si no es nil, la URL del JWKS sale del discovery
+	client    *http.Client
+	mutex     sync.RWMutex
+	remote    []verificationKey
+	fetched   time.Time // última descarga, exitosa o no
+	loaded    bool      // si alguna descarga fue exitosa
+	fetchErr  error     // error de la última descarga
+
+	fetchMutex sync.Mutex
+}
+
+func newKeySet(cfg *config.AuthConfig) (*keySet, error) {
+	ks := &keySet{
+		jwks:   cfg.JWKS,
+		client: &http.Client{Timeout: time.Duration(cfg.JWKS.Timeout) * time.Second},
+	}
+	for _, keyConfig := range cfg.PublicKeys {
+		key, err := loadPublicKey(keyConfig.File)
+		if err != nil {
+			return nil, fmt.Errorf("error loading public key %s: %w", keyConfig.File, err)
+		}
+		ks.static = append(ks.static, verificationKey{kid: keyConfig.KID, key: key})
+	}
+	return ks, nil
+}
+
+// Claves del JWKS publicado en el discovery de un proveedor OIDC
+func newDiscoveredKeySet(discovery *oidcDiscovery, refreshSeconds, timeout int) *keySet {
+	return &keySet{
+		jwks:      config.JWKSConfig{RefreshSeconds: refreshSeconds, Timeout: timeout},
+		discovery: discovery,
+		client:    &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+}
+
+func (ks *keySet) hasRemote() bool {
+	return ks.jwks.URL != "" || ks.discovery != nil
+}
+
+func (ks *keySet) url() (string, error) {
+	if ks.discovery == nil {
+		return ks.jwks.URL, nil
+	}
+	document, err := ks.discovery.get()
+	if err != nil {
+		return "", err
+	}
+	if document.JWKSURI == "" {
+		return "", fmt.Errorf("discovery document of %s has no jwks_uri", ks.discovery.issuer)
+	}
+	return document.JWKSURI, nil
+}
+
+// Refrescar el JWKS periódicamente hasta que se cancele el contexto
+func (ks *keySet) start(ctx context.Context) {
+	if !ks.hasRemote() {
+		return
+	}
+
+	go func() {
+		ks.refresh()
+
+		ticker := time.NewTicker(time.Duration(ks.jwks.RefreshSeconds) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				ks.refresh()
+			}
+		}
+	}()
+}
+
+// Clave para el kid y algoritmo del token. Un kid desconocido puede ser una
+// clave recién rotada en el JWKS, así que se fuerza una descarga.
+func (ks *keySet) lookup(kid, alg string) (crypto.PublicKey, error) {
+	key, err := ks.find(kid, alg)
+	if err == nil || !ks.hasRemote() {
+		return key, err
+	}
+
+	ks.mutex.RLock()
+	recent := time.Since(ks.fetched) < jwksMinRefreshInterval
+	ks.mutex.RUnlock()
+	if !recent && kid != "" {
+		ks.refresh()
+		key, err = ks.find(kid, alg)
+	}
+
+	// Sin ninguna descarga exitosa el token no se puede verificar: el
+	// proveedor no está disponible, no es que el token sea inválido
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	if err != nil && !ks.loaded && ks.fetchErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAuthUnavailable, ks.fetchErr)
+	}
+	return key, err
+}
+
+// Una clave con el mismo kid gana; las claves sin kid sirven para cualquier token
+func (ks *keySet) find(kid, alg string) (crypto.PublicKey, error) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+
+	var candidates []crypto.PublicKey
+	for _, set := range [][]verificationKey{ks.static, ks.remote} {
+		for _, key := range set {
+			if !key.supports(alg) {
+				continue
+			}
+			if kid != "" && key.kid == kid {
+				return key.key, nil
+			}
+			if kid == "" || key.kid == "" {
+				candidates = append(candidates, key.key)
+			}
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) > 1:
+		return nil, fmt.Errorf("%d %s keys match the token, a kid is required", len(candidates), alg)
+	case kid != "":
+		return nil, fmt.Errorf("no %s key found for kid %q", alg, kid)
+	}
+	return nil, fmt.Errorf("no %s key configured", alg)
+}
+
+// Descargar el JWKS; si falla se mantienen las claves anteriores
+func (ks *keySet) refresh() {
+	ks.fetchMutex.Lock()
+	defer ks.fetchMutex.Unlock()
+
+	url, err := ks.url()
+	var keys []verificationKey
+	if err == nil {
+		keys, err = ks.fetch(url)
+	}
+
+	ks.mutex.Lock()
+	ks.fetched = time.Now()
+	ks.fetchErr = err
+	if err == nil {
+		ks.remote = keys
+		ks.loaded = true
+	}
+	previous := len(ks.remote)
+	ks.mutex.Unlock()
+
+	if err != nil {
+		if url == "" {
+			url = ks.discovery.issuer
+		}
+		fmt.Printf("⚠️  JWKS refresh error (%s), keeping %d previous keys: %v\n", url, previous, err)
+		return
+	}
+	fmt.Printf("🔑 JWKS loaded: %d keys from %s\n", len(keys), url)
+}
+
+func (ks *keySet) fetch(url string) ([]verificationKey, error) {
+	resp, err := ks.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid JWKS document: %w", err)
+	}
+
+	keys := make([]verificationKey, 0, len(document.Keys))
+	for _, jwk := range document.Keys {
+		// Claves de cifrado o de tipos no soportados se ignoran
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, verificationKey{kid: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+	return keys, nil
+}
+
+// Clave en formato JWK (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return key, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBase64URL(value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("missing key parameter")
+	}
+	return base64.RawURLEncoding.DecodeString(value)
+}
+
+// Clave pública desde un PEM: PUBLIC KEY, RSA PUBLIC KEY o CERTIFICATE
+func loadPublicKey(file string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
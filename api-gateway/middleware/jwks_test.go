@@ -0,0 +1,342 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/storage"
+	"api-gateway/testutil"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testAudience = "health-api"
+)
+
+// Clave de firma con su JWK público
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	signer crypto.Signer
+}
+
+func newRSASigningKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingKey{kid: kid, method: jwt.SigningMethodRS256, signer: key}
+}
+
+func (k signingKey) jwk() map[string]string {
+	switch public := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "use": "sig", "alg": "RS256",
+			"n": encodeBase64URL(public.N.Bytes()), "e": encodeBase64URL(big.NewInt(int64(public.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": k.kid, "use": "sig", "crv": "P-256",
+			"x": encodeBase64URL(public.X.FillBytes(make([]byte, 32))), "y": encodeBase64URL(public.Y.FillBytes(make([]byte, 32)))}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": k.kid, "use": "sig", "crv": "Ed25519", "x": encodeBase64URL(public)}
+	}
+	return nil
+}
+
+// Token firmado con la clave; mutate permite alterar los claims por caso
+func (k signingKey) token(t *testing.T, mutate func(*Claims)) string {
+	t.Helper()
+
+	claims := &Claims{
+		Username: "maria",
+		Role:     "gestor",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "u-42",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	if mutate != nil {
+		mutate(claims)
+	}
+
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+	signed, err := token.SignedString(k.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// JWKS local que se puede rotar durante el test
+type jwksServer struct {
+	mutex sync.Mutex
+	keys  []signingKey
+}
+
+func (s *jwksServer) publish(keys ...signingKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	document := map[string][]map[string]string{"keys": {}}
+	for _, key := range s.keys {
+		document["keys"] = append(document["keys"], key.jwk())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(document)
+}
+
+func jwksTestConfig(t *testing.T, jwksURL string) *config.Config {
+	t.Helper()
+
+	return testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {
+    "services": [
+      {"name": "leads", "prefix": "/leads", "base_url": "http://127.0.0.1:1"},
+      {"name": "polizas", "prefix": "/polizas", "base_url": "http://127.0.0.1:1",
+       "auth": {"audience": ["polizas"], "clock_skew_seconds": 60}}
+    ]
+  },
+  "auth": {
+    "enabled": true,
+    "jwt_secret": "jwks-check",
+    "token_expiry_hours": 1,
+    "algorithms": ["HS256", "RS256", "ES256", "EdDSA"],
+    "jwks": {"url": %q, "refresh_seconds": 1},
+    "issuer": %q,
+    "audience": [%q]
+  }
+}`, jwksURL, testIssuer, testAudience))
+}
+
+// /leads usa los claims de auth; /polizas define su audience y clock skew
+func newJWKSTestRouter(t *testing.T, cfg *config.Config) *echo.Echo {
+	t.Helper()
+
+	auth, err := NewAuthMiddleware(&cfg.Auth, storage.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	auth.Start(ctx)
+
+	e := newAuthTestRouter()
+	e.GET("/leads", authTestOK, auth.JWTMiddleware())
+	e.GET("/polizas", authTestOK, auth.ServiceJWTMiddleware(cfg.Gateway.Services[1].Auth))
+	return e
+}
+
+func TestJWTMiddlewareAsymmetricKeys(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	rsaSigner := newRSASigningKey(t, "rsa-1")
+	rotatedSigner := newRSASigningKey(t, "rsa-2")
+	forgedSigner := newRSASigningKey(t, "rsa-1")
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecSigner := signingKey{kid: "ec-1", method: jwt.SigningMethodES256, signer: ecKey}
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edSigner := signingKey{kid: "ed-1", method: jwt.SigningMethodEdDSA, signer: edKey}
+
+	jwks := &jwksServer{}
+	jwks.publish(rsaSigner, ecSigner, edSigner)
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	cfg := jwksTestConfig(t, server.URL)
+	e := newJWKSTestRouter(t, cfg)
+
+	expired := func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second)) }
+	polizasAudience := func(c *Claims) { c.Audience = jwt.ClaimStrings{"polizas"} }
+
+	noneToken, _ := jwt.NewWithClaims(jwt.SigningMethodNone, &Claims{UserID: "admin"}).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID:           "u-7",
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: testIssuer, Audience: jwt.ClaimStrings{testAudience}},
+	}).SignedString([]byte("jwks-check"))
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+	}{
+		{"RS256 with a JWKS kid", "/leads", rsaSigner.token(t, nil), http.StatusOK},
+		{"ES256 with a JWKS kid", "/leads", ecSigner.token(t, nil), http.StatusOK},
+		{"EdDSA with a JWKS kid", "/leads", edSigner.token(t, nil), http.StatusOK},
+		{"HS256 with jwt_secret", "/leads", hmacToken, http.StatusOK},
+		{"known kid signed with another key", "/leads", forgedSigner.token(t, nil), http.StatusUnauthorized},
+		{"kid missing from the JWKS", "/leads", rotatedSigner.token(t, nil), http.StatusUnauthorized},
+		{"alg none", "/leads", noneToken, http.StatusUnauthorized},
+		{"different issuer", "/leads", rsaSigner.token(t, func(c *Claims) { c.Issuer = "https://otro.example.com" }), http.StatusUnauthorized},
+		{"auth audience on a service with its own audience", "/polizas", rsaSigner.token(t, nil), http.StatusUnauthorized},
+		{"service audience", "/polizas", rsaSigner.token(t, polizasAudience), http.StatusOK},
+		{"expired 30s ago without clock skew", "/leads", rsaSigner.token(t, expired), http.StatusUnauthorized},
+		{"expired 30s ago with a 60s clock skew", "/polizas", rsaSigner.token(t, func(c *Claims) { expired(c); polizasAudience(c) }), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := authTestRequest(e, tt.path, tt.token)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
+
+// La clave nueva se publica en el JWKS y el refresco en segundo plano la toma
+func TestJWKSKeyRotation(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	currentSigner := newRSASigningKey(t, "rsa-1")
+	rotatedSigner := newRSASigningKey(t, "rsa-2")
+
+	jwks := &jwksServer{}
+	jwks.publish(currentSigner)
+	server := httptest.NewServer(jwks)
+	defer server.Close()
+
+	e := newJWKSTestRouter(t, jwksTestConfig(t, server.URL))
+
+	if rec := authTestRequest(e, "/leads", currentSigner.token(t, nil)); rec.Code != http.StatusOK {
+		t.Fatalf("current key: status = %d, want 200 (%s)", rec.Code, rec.Body.String())
+	}
+	if rec := authTestRequest(e, "/leads", rotatedSigner.token(t, nil)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unpublished key: status = %d, want 401", rec.Code)
+	}
+
+	jwks.publish(rotatedSigner)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := authTestRequest(e, "/leads", rotatedSigner.token(t, nil))
+		if rec.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("rotated key not accepted after the JWKS refresh: status %d (%s)", rec.Code, rec.Body.String())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if rec := authTestRequest(e, "/leads", currentSigner.token(t, nil)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("retired key: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestJWTMiddlewarePEMPublicKeys(t *testing.T) {
+	rsaSigner := newRSASigningKey(t, "")
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edSigner := signingKey{kid: "ed-1", method: jwt.SigningMethodEdDSA, signer: edKey}
+	otherSigner := newRSASigningKey(t, "")
+
+	dir := t.TempDir()
+	writePEM := func(name string, signer signingKey) string {
+		der, err := x509.MarshalPKIXPublicKey(signer.signer.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	cfg := testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {"services": [{"name": "leads", "prefix": "/leads", "base_url": "http://127.0.0.1:1"}]},
+  "auth": {
+    "enabled": true,
+    "token_expiry_hours": 1,
+    "algorithms": ["RS256", "EdDSA"],
+    "public_keys": [{"file": %q}, {"kid": "ed-1", "file": %q}]
+  }
+}`, writePEM("rsa.pem", rsaSigner), writePEM("ed.pem", edSigner)))
+
+	auth, err := NewAuthMiddleware(&cfg.Auth, storage.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newAuthTestRouter()
+	e.GET("/leads", authTestOK, auth.JWTMiddleware())
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"RS256 key without kid", rsaSigner.token(t, nil), http.StatusOK},
+		{"EdDSA key selected by kid", edSigner.token(t, nil), http.StatusOK},
+		{"RS256 signed with an unknown key", otherSigner.token(t, nil), http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := authTestRequest(e, "/leads", tt.token)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+}
+
+// Router de prueba que responde los errores del gateway con su status y código
+func newAuthTestRouter() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		gwErr := AsGatewayError(err)
+		c.JSON(gwErr.Status, map[string]string{"code": gwErr.Code, "error": gwErr.Error()})
+	}
+	return e
+}
+
+func authTestOK(c echo.Context) error {
+	return c.String(http.StatusOK, c.Get("user_id").(string))
+}
+
+func authTestRequest(e *echo.Echo, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
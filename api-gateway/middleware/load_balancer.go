@@ -28,7 +28,7 @@ type RoundRobinLB struct {
 func NewRoundRobinLB(backends []string) *RoundRobinLB {
 	return &RoundRobinLB{
 		backends:        backends,
-		healthyBackends: make([]string, len(backends)),
+		healthyBackends: make([]string, 0, len(backends)),
 	}
 }
 
@@ -103,7 +103,7 @@ type RandomLB struct {
 func NewRandomLB(backends []string) *RandomLB {
 	return &RandomLB{
 		backends:        backends,
-		healthyBackends: make([]string, len(backends)),
+		healthyBackends: make([]string, 0, len(backends)),
 		rand:            rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
@@ -385,6 +385,11 @@ func (lb *LeastConnectionsLB) GetHealthyBackends() []string {
 
 // Factory para crear load balancers
 func NewLoadBalancer(config config.LoadBalancerConfig) LoadBalancer {
+	// Con grupos (canary) cada grupo tiene su propio load balancer
+	if config.Enabled && len(config.Groups) > 0 {
+		return NewTrafficSplitter(config)
+	}
+
 	if !config.Enabled || len(config.Backends) == 0 {
 		return nil
 	}
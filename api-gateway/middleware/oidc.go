@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tiempo mínimo entre intentos de discovery fallidos
+const discoveryRetryInterval = 10 * time.Second
+
+// Campos de /.well-known/openid-configuration que usa el gateway
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	JWKSURI               string `json:"jwks_uri"`
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+}
+
+// Discovery OIDC de un issuer. El documento se descarga en el primer uso y se
+// conserva; si la descarga falla se reintenta en el siguiente uso.
+type oidcDiscovery struct {
+	issuer    string
+	client    *http.Client
+	mutex     sync.Mutex
+	document  *discoveryDocument
+	attempted time.Time
+	err       error
+}
+
+func newOIDCDiscovery(issuer string, timeout int) *oidcDiscovery {
+	return &oidcDiscovery{
+		issuer: issuer,
+		client: &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+}
+
+func (d *oidcDiscovery) get() (*discoveryDocument, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.document != nil {
+		return d.document, nil
+	}
+	if time.Since(d.attempted) < discoveryRetryInterval {
+		return nil, d.err
+	}
+	d.attempted = time.Now()
+
+	document, err := d.fetch()
+	if err != nil {
+		d.err = fmt.Errorf("OIDC discovery of %s failed: %w", d.issuer, err)
+		return nil, d.err
+	}
+	d.document = document
+	fmt.Printf("🔎 OIDC discovery loaded: %s\n", d.issuer)
+	return document, nil
+}
+
+func (d *oidcDiscovery) fetch() (*discoveryDocument, error) {
+	resp, err := d.client.Get(strings.TrimSuffix(d.issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var document discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid discovery document: %w", err)
+	}
+	// El documento tiene que ser del mismo issuer que exigen los tokens
+	if document.Issuer != d.issuer {
+		return nil, fmt.Errorf("discovery document issuer %q does not match", document.Issuer)
+	}
+	return &document, nil
+}
@@ -2,26 +2,30 @@ package middleware
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"sync"
 	"time"
 
 	"api-gateway/config"
+	"api-gateway/metrics"
+	"api-gateway/storage"
 
 	"github.com/labstack/echo/v4"
 	"golang.org/x/time/rate"
 )
 
 type RateLimiter struct {
-	limiters map[string]*rate.Limiter
-	mutex    sync.RWMutex
-	config   config.RateLimitConfig
+	name   string
+	store  storage.Store
+	config config.RateLimitConfig
 }
 
-func NewRateLimiter(config config.RateLimitConfig) *RateLimiter {
+func NewRateLimiter(name string, config config.RateLimitConfig, store storage.Store) *RateLimiter {
 	return &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
-		config:   config,
+		name:   name,
+		store:  store,
+		config: config,
 	}
 }
 
@@ -36,33 +40,39 @@ func (rl *RateLimiter) RateLimitMiddleware() echo.MiddlewareFunc {
 			// Obtener identificador del cliente (IP + User Agent para más precisión)
 			clientID := rl.getClientID(c)
 
-			// Obtener o crear rate limiter para este cliente
-			limiter := rl.getLimiter(clientID)
+			// Consumir un token del bucket del cliente en el store compartido
+			result, err := rl.store.Allow(
+				c.Request().Context(),
+				"ratelimit:"+rl.name+":"+clientID,
+				float64(rl.config.RequestsPerSecond),
+				rl.config.BurstSize,
+			)
+			if err != nil {
+				// Si el store no responde, no bloquear el tráfico
+				fmt.Printf("⚠️  Rate limit store error [%s]: %v\n", rl.name, err)
+				return next(c)
+			}
 
 			// Verificar si puede procesar la request
-			if !limiter.Allow() {
-				// Calcular tiempo de espera hasta la próxima request permitida
-				reservation := limiter.Reserve()
-				delay := reservation.Delay()
-				reservation.Cancel() // Cancelar la reserva ya que vamos a rechazar
+			if !result.Allowed {
+				metrics.RateLimitRejections.WithLabelValues(rl.name).Inc()
+				delay := result.RetryAfter
 
 				// Headers informativos
 				c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rl.config.RequestsPerSecond))
 				c.Response().Header().Set("X-RateLimit-Remaining", "0")
 				c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(delay).Unix()))
-				c.Response().Header().Set("Retry-After", fmt.Sprintf("%.0f", delay.Seconds()))
+				c.Response().Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(delay.Seconds())))
 
-				return echo.NewHTTPError(http.StatusTooManyRequests, map[string]interface{}{
-					"error":       "Rate limit exceeded",
+				return NewGatewayError(http.StatusTooManyRequests, LayerRateLimit, CodeRateLimited, "Rate limit exceeded").WithDetails(map[string]interface{}{
 					"retry_after": fmt.Sprintf("%.2f seconds", delay.Seconds()),
 					"limit":       rl.config.RequestsPerSecond,
 				})
 			}
 
 			// Request permitida, agregar headers informativos
-			remaining := rl.config.BurstSize - 1 // Aproximación
 			c.Response().Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", rl.config.RequestsPerSecond))
-			c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+			c.Response().Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", result.Remaining))
 			c.Response().Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Minute).Unix()))
 
 			return next(c)
@@ -83,60 +93,17 @@ func (rl *RateLimiter) getClientID(c echo.Context) string {
 	return fmt.Sprintf("ip:%s:ua:%s", ip, userAgent[:min(len(userAgent), 50)])
 }
 
-func (rl *RateLimiter) getLimiter(clientID string) *rate.Limiter {
-	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
-
-	limiter, exists := rl.limiters[clientID]
-	if !exists {
-		// Crear nuevo rate limiter para este cliente
-		limiter = rate.NewLimiter(
-			rate.Limit(rl.config.RequestsPerSecond),
-			rl.config.BurstSize,
-		)
-		rl.limiters[clientID] = limiter
-	}
-
-	return limiter
-}
-
-// Limpiar rate limiters inactivos periódicamente
-func (rl *RateLimiter) StartCleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
-	go func() {
-		for range ticker.C {
-			rl.cleanup()
-		}
-	}()
-}
-
-func (rl *RateLimiter) cleanup() {
-	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
-
-	time.Now()
-	for clientID, limiter := range rl.limiters {
-		// Si el limiter permite el burst completo, probablemente no se ha usado recientemente
-		if limiter.Tokens() == float64(rl.config.BurstSize) {
-			// Verificar si no se ha usado en los últimos 10 minutos
-			reservation := limiter.Reserve()
-			if reservation.OK() && reservation.Delay() == 0 {
-				delete(rl.limiters, clientID)
-			}
-			reservation.Cancel()
-		}
-	}
-}
-
 // Rate Limiter específico por endpoint
 type EndpointRateLimiter struct {
 	limiters map[string]*RateLimiter
+	store    storage.Store
 	mutex    sync.RWMutex
 }
 
-func NewEndpointRateLimiter() *EndpointRateLimiter {
+func NewEndpointRateLimiter(store storage.Store) *EndpointRateLimiter {
 	return &EndpointRateLimiter{
 		limiters: make(map[string]*RateLimiter),
+		store:    store,
 	}
 }
 
@@ -144,7 +111,7 @@ func (erl *EndpointRateLimiter) AddEndpoint(endpoint string, config config.RateL
 	erl.mutex.Lock()
 	defer erl.mutex.Unlock()
 
-	erl.limiters[endpoint] = NewRateLimiter(config)
+	erl.limiters[endpoint] = NewRateLimiter(endpoint, config, erl.store)
 }
 
 func (erl *EndpointRateLimiter) EndpointRateLimitMiddleware() echo.MiddlewareFunc {
@@ -187,7 +154,7 @@ func (trl *TieredRateLimiter) TieredRateLimitMiddleware() echo.MiddlewareFunc {
 		return func(c echo.Context) error {
 			// Verificar límite global primero
 			if !trl.global.Allow() {
-				return echo.NewHTTPError(http.StatusTooManyRequests, "Global rate limit exceeded")
+				return NewGatewayError(http.StatusTooManyRequests, LayerRateLimit, CodeRateLimited, "Global rate limit exceeded")
 			}
 
 			// Determinar tier del usuario
@@ -204,7 +171,7 @@ func (trl *TieredRateLimiter) TieredRateLimitMiddleware() echo.MiddlewareFunc {
 			}
 
 			if !limiter.Allow() {
-				return echo.NewHTTPError(http.StatusTooManyRequests,
+				return NewGatewayError(http.StatusTooManyRequests, LayerRateLimit, CodeRateLimited,
 					fmt.Sprintf("Rate limit exceeded for %s tier", tier))
 			}
 
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Valor de token_use en los refresh tokens; no se aceptan como access tokens
+const refreshTokenUse = "refresh"
+
+// Par de tokens que devuelven /auth/token y /auth/refresh
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`         // segundos
+	RefreshExpiresIn int64  `json:"refresh_expires_in"` // segundos
+}
+
+// Si auth.users está configurado
+func (am *AuthMiddleware) TokenIssuanceEnabled() bool {
+	return am.users != nil
+}
+
+// Emitir un par de tokens para un usuario con credenciales válidas
+func (am *AuthMiddleware) IssueTokens(ctx context.Context, username, password string) (*TokenPair, error) {
+	user, err := am.users.Authenticate(ctx, username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		return nil, NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidCredentials, "Invalid username or password")
+	}
+	if err != nil {
+		return nil, NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "User source unavailable").WithCause(err)
+	}
+
+	// Cada login abre una sesión; los refresh tokens rotados la conservan
+	return am.tokenPair(user, newTokenID(), time.Now())
+}
+
+// Renovar con un refresh token: el token usado queda revocado y se emite un
+// par nuevo. Presentar un refresh token ya usado revoca toda la sesión, porque
+// significa que otro cliente tiene una copia. El token se marca como usado con
+// SetNX, así que de dos renovaciones concurrentes con el mismo token solo una
+// recibe tokens nuevos.
+func (am *AuthMiddleware) RefreshTokens(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := am.parseRefreshToken(refreshToken, time.Now())
+	if err != nil {
+		return nil, NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidToken, "Invalid refresh token").WithCause(err)
+	}
+
+	sessionRevoked, err := am.revoked(ctx, revokedSessionKey(claims.SessionID))
+	if err != nil {
+		return nil, NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "Token store unavailable").WithCause(err)
+	}
+	if sessionRevoked {
+		return nil, NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidToken, "Session has been revoked")
+	}
+
+	// Con la denylist, un subject revocado tampoco puede renovar sus tokens
+	if am.denylist != nil {
+		reason, err := am.denylist.Check(ctx, claims)
+		if err != nil {
+			return nil, NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "Token denylist unavailable").WithCause(err)
+		}
+		if reason != "" {
+			return nil, NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeTokenRevoked, "Refresh token has been revoked").WithCause(errors.New(reason))
+		}
+	}
+
+	user, err := am.users.Lookup(ctx, claims.Username)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidToken, "User not found or disabled")
+	}
+	if err != nil {
+		return nil, NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "User source unavailable").WithCause(err)
+	}
+
+	// Marcar el token como usado hasta su exp (después ya no es válido de todos
+	// modos). Si ya estaba marcado, otro cliente lo usó antes.
+	claimed, err := am.store.SetNX(ctx, revokedRefreshKey(claims.ID), []byte("1"), time.Until(claims.ExpiresAt.Time))
+	if err != nil {
+		return nil, NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "Token store unavailable").WithCause(err)
+	}
+	if !claimed {
+		if err := am.revokeSession(ctx, claims.SessionID); err != nil {
+			fmt.Printf("⚠️  Session revocation error [%s]: %v\n", claims.SessionID, err)
+		}
+		fmt.Printf("⚠️  Refresh token reuse detected, session revoked: %s (%s)\n", claims.SessionID, claims.Username)
+		return nil, NewGatewayError(http.StatusUnauthorized, LayerAuth, CodeInvalidToken, "Refresh token has already been used")
+	}
+	return am.tokenPair(user, claims.SessionID, time.Now())
+}
+
+// Revocar la sesión de un refresh token (logout). Como en RFC 7009, un token
+// inválido o ya revocado no es un error.
+func (am *AuthMiddleware) RevokeToken(ctx context.Context, refreshToken string) error {
+	claims, err := am.parseRefreshToken(refreshToken, time.Now())
+	if err != nil {
+		return nil
+	}
+	if err := am.revokeSession(ctx, claims.SessionID); err != nil {
+		return NewGatewayError(http.StatusServiceUnavailable, LayerAuth, CodeAuthUnavailable, "Token store unavailable").WithCause(err)
+	}
+	return nil
+}
+
+func (am *AuthMiddleware) tokenPair(user *User, sessionID string, now time.Time) (*TokenPair, error) {
+	accessExpiry := time.Duration(am.config.TokenExpiry) * time.Hour
+	refreshExpiry := time.Duration(am.config.RefreshExpiry) * time.Hour
+
+	access, err := am.sign(am.userClaims(user, sessionID, now, accessExpiry))
+	if err != nil {
+		return nil, err
+	}
+
+	refreshClaims := am.userClaims(user, sessionID, now, refreshExpiry)
+	refreshClaims.TokenUse = refreshTokenUse
+	refresh, err := am.sign(refreshClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(accessExpiry.Seconds()),
+		RefreshExpiresIn: int64(refreshExpiry.Seconds()),
+	}, nil
+}
+
+// Claims de un token emitido por el gateway; cada token tiene su propio jti
+func (am *AuthMiddleware) userClaims(user *User, sessionID string, now time.Time, expiry time.Duration) *Claims {
+	issuer := am.config.Issuer
+	if issuer == "" {
+		issuer = "api-gateway"
+	}
+	return &Claims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
+			Subject:   user.ID,
+			Issuer:    issuer,
+			Audience:  am.config.Audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func (am *AuthMiddleware) sign(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(am.config.JWTSecret))
+}
+
+// Refresh token firmado por el gateway, vigente y con jti y sesión
+func (am *AuthMiddleware) parseRefreshToken(tokenString string, now time.Time) (*Claims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"HS256"}), jwt.WithoutClaimsValidation())
+	claims := &Claims{}
+	_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(am.config.JWTSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.TokenUse != refreshTokenUse {
+		return nil, fmt.Errorf("not a refresh token")
+	}
+	if claims.ID == "" || claims.SessionID == "" || claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("refresh token without jti, sid or exp")
+	}
+	policy := am.providers["local"].policy
+	policy.clockSkew = 0
+	if err := policy.verify(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (am *AuthMiddleware) revoked(ctx context.Context, key string) (bool, error) {
+	_, found, err := am.store.Get(ctx, key)
+	return found, err
+}
+
+// Ningún refresh token de la sesión vive más que refresh_expiry_hours desde ahora
+func (am *AuthMiddleware) revokeSession(ctx context.Context, sessionID string) error {
+	ttl := time.Duration(am.config.RefreshExpiry) * time.Hour
+	return am.store.Set(ctx, revokedSessionKey(sessionID), []byte("1"), ttl)
+}
+
+func revokedRefreshKey(id string) string {
+	return "auth:revoked:refresh:" + id
+}
+
+func revokedSessionKey(id string) string {
+	return "auth:revoked:session:" + id
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/storage"
+	"api-gateway/testutil"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Configuración con un archivo de usuarios: maria (activo) y baja (deshabilitado),
+// ambos con password s3creto
+func tokenTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3creto"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usersPath := filepath.Join(t.TempDir(), "users.json")
+	users := fmt.Sprintf(`{"users": [
+  {"id": "u-42", "username": "maria", "password_hash": %[1]q, "role": "gestor"},
+  {"id": "u-43", "username": "baja", "password_hash": %[1]q, "role": "gestor", "disabled": true}
+]}`, hash)
+	if err := os.WriteFile(usersPath, []byte(users), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {"services": [{"name": "leads", "prefix": "/leads", "base_url": "http://127.0.0.1:1"}]},
+  "auth": {
+    "enabled": true,
+    "jwt_secret": "token-check",
+    "token_expiry_hours": 1,
+    "refresh_expiry_hours": 24,
+    "users": {"type": "file", "file": %q}
+  }
+}`, usersPath))
+}
+
+func newTokenTestAuth(t *testing.T, cfg *config.Config, store storage.Store) (*AuthMiddleware, *echo.Echo) {
+	t.Helper()
+
+	auth, err := NewAuthMiddleware(&cfg.Auth, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newAuthTestRouter()
+	e.GET("/leads", authTestOK, auth.JWTMiddleware())
+	return auth, e
+}
+
+func expectGatewayCode(t *testing.T, err error, code string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expected %s, got no error", code)
+	}
+	if gwErr := AsGatewayError(err); gwErr.Code != code {
+		t.Fatalf("expected %s, got %s (%v)", code, gwErr.Code, err)
+	}
+}
+
+func expectAccessStatus(t *testing.T, e *echo.Echo, token string, status int) {
+	t.Helper()
+
+	if rec := authTestRequest(e, "/leads", token); rec.Code != status {
+		t.Fatalf("status = %d, want %d (%s)", rec.Code, status, rec.Body.String())
+	}
+}
+
+func TestIssueTokensCredentials(t *testing.T) {
+	auth, e := newTokenTestAuth(t, tokenTestConfig(t), storage.NewMemoryStore())
+	ctx := context.Background()
+
+	pair, err := auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	expectAccessStatus(t, e, pair.AccessToken, http.StatusOK)
+
+	// Los refresh tokens no se aceptan como access tokens
+	expectAccessStatus(t, e, pair.RefreshToken, http.StatusUnauthorized)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "maria", "otro"},
+		{"unknown user", "pepe", "s3creto"},
+		{"disabled user", "baja", "s3creto"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.IssueTokens(ctx, tt.username, tt.password)
+			expectGatewayCode(t, err, CodeInvalidCredentials)
+		})
+	}
+}
+
+func TestRefreshTokensRotation(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	auth, e := newTokenTestAuth(t, tokenTestConfig(t), storage.NewMemoryStore())
+	ctx := context.Background()
+
+	first, err := auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = auth.RefreshTokens(ctx, first.AccessToken)
+	expectGatewayCode(t, err, CodeInvalidToken)
+
+	rotated, err := auth.RefreshTokens(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if rotated.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	expectAccessStatus(t, e, rotated.AccessToken, http.StatusOK)
+
+	// Reusar el refresh token anterior revoca toda la sesión
+	_, err = auth.RefreshTokens(ctx, first.RefreshToken)
+	expectGatewayCode(t, err, CodeInvalidToken)
+	_, err = auth.RefreshTokens(ctx, rotated.RefreshToken)
+	expectGatewayCode(t, err, CodeInvalidToken)
+}
+
+// Store que demora las respuestas de Get, para que las requests concurrentes
+// lean el mismo valor antes de que alguna escriba
+type slowReadStore struct {
+	storage.Store
+}
+
+func (s slowReadStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, found, err := s.Store.Get(ctx, key)
+	time.Sleep(10 * time.Millisecond)
+	return value, found, err
+}
+
+// Dos renovaciones concurrentes con el mismo refresh token: solo una recibe tokens
+func TestRefreshTokensConcurrentReuse(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	auth, _ := newTokenTestAuth(t, tokenTestConfig(t), slowReadStore{storage.NewMemoryStore()})
+	ctx := context.Background()
+
+	pair, err := auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers = 10
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := auth.RefreshTokens(ctx, pair.RefreshToken); err == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("%d concurrent refreshes succeeded, want exactly 1", succeeded)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	auth, _ := newTokenTestAuth(t, tokenTestConfig(t), storage.NewMemoryStore())
+	ctx := context.Background()
+
+	revoked, err := auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := auth.IssueTokens(ctx, "maria", "s3creto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := auth.RevokeToken(ctx, revoked.RefreshToken); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	_, err = auth.RefreshTokens(ctx, revoked.RefreshToken)
+	expectGatewayCode(t, err, CodeInvalidToken)
+
+	// El logout no afecta a otras sesiones del usuario
+	if _, err := auth.RefreshTokens(ctx, other.RefreshToken); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+
+	// Como en RFC 7009, revocar un token inválido no es un error
+	if err := auth.RevokeToken(ctx, "no-es-un-jwt"); err != nil {
+		t.Errorf("RevokeToken(invalid) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"api-gateway/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Reparto de tráfico entre grupos de backends (p.ej. stable y canary).
+// Cada grupo tiene su propio load balancer con la estrategia del servicio.
+type TrafficSplitter struct {
+	names     []string
+	groups    map[string]LoadBalancer
+	overrides []config.GroupOverrideConfig
+	stickyBy  string
+
+	mutex   sync.RWMutex
+	weights map[string]int
+}
+
+func NewTrafficSplitter(cfg config.LoadBalancerConfig) *TrafficSplitter {
+	ts := &TrafficSplitter{
+		groups:    make(map[string]LoadBalancer),
+		overrides: cfg.Overrides,
+		stickyBy:  cfg.StickyBy,
+		weights:   make(map[string]int),
+	}
+
+	for _, group := range cfg.Groups {
+		ts.names = append(ts.names, group.Name)
+		ts.weights[group.Name] = group.Weight
+		ts.groups[group.Name] = NewLoadBalancer(config.LoadBalancerConfig{
+			Strategy: cfg.Strategy,
+			Backends: group.Backends,
+			Enabled:  true,
+		})
+	}
+
+	return ts
+}
+
+// Grupo que atiende la request: primero los overrides, después la
+// asignación sticky (hash de la clave del cliente) o aleatoria según el peso
+func (ts *TrafficSplitter) SelectGroup(c echo.Context) string {
+	for _, override := range ts.overrides {
+		if overrideMatches(c, override) {
+			return override.Group
+		}
+	}
+
+	bucket := rand.Intn(100)
+	if key := ts.stickyKey(c); key != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		bucket = int(hash.Sum32() % 100)
+	}
+	return ts.groupForBucket(bucket)
+}
+
+// Los grupos ocupan rangos consecutivos de 0 a 99 en el orden de la configuración
+func (ts *TrafficSplitter) groupForBucket(bucket int) string {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+
+	cumulative := 0
+	for _, name := range ts.names {
+		cumulative += ts.weights[name]
+		if bucket < cumulative {
+			return name
+		}
+	}
+	return ts.names[len(ts.names)-1]
+}
+
+func (ts *TrafficSplitter) stickyKey(c echo.Context) string {
+	switch {
+	case ts.stickyBy == "ip":
+		return c.RealIP()
+	case ts.stickyBy == "user_id":
+		userID, _ := c.Get("user_id").(string)
+		return userID
+	case strings.HasPrefix(ts.stickyBy, "header:"):
+		return c.Request().Header.Get(strings.TrimPrefix(ts.stickyBy, "header:"))
+	case strings.HasPrefix(ts.stickyBy, "cookie:"):
+		if cookie, err := c.Cookie(strings.TrimPrefix(ts.stickyBy, "cookie:")); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func overrideMatches(c echo.Context, override config.GroupOverrideConfig) bool {
+	var value string
+	switch {
+	case override.Header != "":
+		value = c.Request().Header.Get(override.Header)
+	case override.Cookie != "":
+		if cookie, err := c.Cookie(override.Cookie); err == nil {
+			value = cookie.Value
+		}
+	case override.Claim != "":
+		value = claimValue(c, override.Claim)
+	}
+
+	if value == "" {
+		return false
+	}
+	return override.Value == "" || override.Value == value
+}
+
+// Valor de un claim del token verificado por el JWT middleware. Solo están
+// los que guarda Claims; config/validate.go rechaza los demás.
+func claimValue(c echo.Context, name string) string {
+	claims, ok := c.Get("claims").(*Claims)
+	if !ok {
+		return ""
+	}
+	switch name {
+	case "user_id":
+		return claims.UserID
+	case "username":
+		return claims.Username
+	case "role":
+		return claims.Role
+	case "sub":
+		return claims.Subject
+	}
+	return ""
+}
+
+// Siguiente backend dentro de un grupo
+func (ts *TrafficSplitter) NextBackendIn(group string) string {
+	lb, exists := ts.groups[group]
+	if !exists {
+		return ""
+	}
+	return lb.NextBackend()
+}
+
+// Nombres de los grupos en el orden de la configuración
+func (ts *TrafficSplitter) Groups() []string {
+	return append([]string(nil), ts.names...)
+}
+
+// Backends saludables de un grupo
+func (ts *TrafficSplitter) GroupBackends(group string) []string {
+	lb, exists := ts.groups[group]
+	if !exists {
+		return nil
+	}
+	return lb.GetHealthyBackends()
+}
+
+func (ts *TrafficSplitter) Weights() map[string]int {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+
+	weights := make(map[string]int, len(ts.weights))
+	for name, weight := range ts.weights {
+		weights[name] = weight
+	}
+	return weights
+}
+
+// Cambiar los pesos en caliente; los grupos omitidos mantienen su peso
+func (ts *TrafficSplitter) SetWeights(weights map[string]int) error {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	merged := make(map[string]int, len(ts.weights))
+	for name, weight := range ts.weights {
+		merged[name] = weight
+	}
+	for name, weight := range weights {
+		if _, exists := ts.groups[name]; !exists {
+			return fmt.Errorf("unknown group %q", name)
+		}
+		if weight < 0 || weight > 100 {
+			return fmt.Errorf("weight for group %q must be between 0 and 100", name)
+		}
+		merged[name] = weight
+	}
+
+	total := 0
+	for _, weight := range merged {
+		total += weight
+	}
+	if total != 100 {
+		return fmt.Errorf("weights must add up to 100, got %d", total)
+	}
+
+	ts.weights = merged
+	return nil
+}
+
+// Implementación de LoadBalancer sin contexto de request: elige el grupo por peso
+
+func (ts *TrafficSplitter) NextBackend() string {
+	return ts.NextBackendIn(ts.groupForBucket(rand.Intn(100)))
+}
+
+func (ts *TrafficSplitter) MarkBackendDown(backend string) {
+	for _, lb := range ts.groups {
+		lb.MarkBackendDown(backend)
+	}
+}
+
+func (ts *TrafficSplitter) MarkBackendUp(backend string) {
+	for _, lb := range ts.groups {
+		lb.MarkBackendUp(backend)
+	}
+}
+
+func (ts *TrafficSplitter) GetHealthyBackends() []string {
+	var healthy []string
+	for _, name := range ts.names {
+		healthy = append(healthy, ts.groups[name].GetHealthyBackends()...)
+	}
+	return healthy
+}
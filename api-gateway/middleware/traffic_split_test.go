@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"api-gateway/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// Grupos stable (100%) y canary (0%): canary solo se elige por override
+func newTestSplitter(overrides []config.GroupOverrideConfig, stickyBy string) *TrafficSplitter {
+	return NewTrafficSplitter(config.LoadBalancerConfig{
+		Enabled:  true,
+		Strategy: "round_robin",
+		Groups: []config.BackendGroupConfig{
+			{Name: "stable", Weight: 100, Backends: []string{"http://stable-1", "http://stable-2"}},
+			{Name: "canary", Weight: 0, Backends: []string{"http://canary-1"}},
+		},
+		Overrides: overrides,
+		StickyBy:  stickyBy,
+	})
+}
+
+func TestTrafficSplitterOverrides(t *testing.T) {
+	verified := &Claims{UserID: "42", Username: "maria", Role: "beta", RegisteredClaims: jwt.RegisteredClaims{Subject: "auth0|42"}}
+
+	tests := []struct {
+		name     string
+		override config.GroupOverrideConfig
+		header   string
+		cookie   string
+		claims   *Claims
+		want     string
+	}{
+		{"header with value", config.GroupOverrideConfig{Header: "X-Canary", Value: "1"}, "1", "", nil, "canary"},
+		{"header with another value", config.GroupOverrideConfig{Header: "X-Canary", Value: "1"}, "0", "", nil, "stable"},
+		{"header presence", config.GroupOverrideConfig{Header: "X-Canary"}, "yes", "", nil, "canary"},
+		{"missing header", config.GroupOverrideConfig{Header: "X-Canary"}, "", "", nil, "stable"},
+		{"cookie", config.GroupOverrideConfig{Cookie: "canary", Value: "on"}, "", "on", nil, "canary"},
+		{"user_id claim", config.GroupOverrideConfig{Claim: "user_id", Value: "42"}, "", "", verified, "canary"},
+		{"role claim", config.GroupOverrideConfig{Claim: "role", Value: "beta"}, "", "", verified, "canary"},
+		{"sub claim", config.GroupOverrideConfig{Claim: "sub", Value: "auth0|42"}, "", "", verified, "canary"},
+		{"claim with another value", config.GroupOverrideConfig{Claim: "username", Value: "jorge"}, "", "", verified, "stable"},
+		{"claim without a verified token", config.GroupOverrideConfig{Claim: "user_id"}, "", "", nil, "stable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			override := tt.override
+			override.Group = "canary"
+			splitter := newTestSplitter([]config.GroupOverrideConfig{override}, "")
+
+			req := httptest.NewRequest(http.MethodGet, "/leads", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Canary", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "canary", Value: tt.cookie})
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+			if tt.claims != nil {
+				c.Set("claims", tt.claims)
+			}
+
+			if got := splitter.SelectGroup(c); got != tt.want {
+				t.Errorf("SelectGroup = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrafficSplitterGroupForBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+		buckets map[int]string
+	}{
+		{"90/10", map[string]int{"stable": 90, "canary": 10}, map[int]string{0: "stable", 89: "stable", 90: "canary", 99: "canary"}},
+		{"50/50", map[string]int{"stable": 50, "canary": 50}, map[int]string{49: "stable", 50: "canary"}},
+		{"all canary", map[string]int{"stable": 0, "canary": 100}, map[int]string{0: "canary", 99: "canary"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splitter := newTestSplitter(nil, "")
+			if err := splitter.SetWeights(tt.weights); err != nil {
+				t.Fatal(err)
+			}
+			for bucket, want := range tt.buckets {
+				if got := splitter.groupForBucket(bucket); got != want {
+					t.Errorf("bucket %d -> %s, want %s", bucket, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrafficSplitterSetWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+		want    map[string]int // nil = se rechaza y quedan los pesos anteriores
+	}{
+		{"both groups", map[string]int{"stable": 70, "canary": 30}, map[string]int{"stable": 70, "canary": 30}},
+		{"unknown group", map[string]int{"beta": 100}, nil},
+		{"weight out of range", map[string]int{"stable": 120, "canary": -20}, nil},
+		{"omitted group keeps its weight", map[string]int{"stable": 100}, map[string]int{"stable": 100, "canary": 0}},
+		{"omitted group counts in the total", map[string]int{"canary": 10}, nil}, // 100 + 10
+		{"total is not 100", map[string]int{"stable": 60, "canary": 30}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splitter := newTestSplitter(nil, "")
+			err := splitter.SetWeights(tt.weights)
+			if (err == nil) != (tt.want != nil) {
+				t.Fatalf("SetWeights error = %v, want accepted = %v", err, tt.want != nil)
+			}
+
+			want := tt.want
+			if want == nil {
+				want = map[string]int{"stable": 100, "canary": 0}
+			}
+			if got := splitter.Weights(); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("weights = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// La asignación sticky es estable para la misma clave y respeta los pesos
+// en el conjunto de clientes
+func TestTrafficSplitterSticky(t *testing.T) {
+	tests := []struct {
+		stickyBy string
+		request  func(req *http.Request, c echo.Context, key string)
+	}{
+		{"ip", func(req *http.Request, c echo.Context, key string) { req.RemoteAddr = key + ":1234" }},
+		{"user_id", func(req *http.Request, c echo.Context, key string) { c.Set("user_id", key) }},
+		{"header:X-Client-ID", func(req *http.Request, c echo.Context, key string) { req.Header.Set("X-Client-ID", key) }},
+		{"cookie:session", func(req *http.Request, c echo.Context, key string) {
+			req.AddCookie(&http.Cookie{Name: "session", Value: key})
+		}},
+	}
+
+	const clients = 2000
+	for _, tt := range tests {
+		t.Run(tt.stickyBy, func(t *testing.T) {
+			splitter := newTestSplitter(nil, tt.stickyBy)
+			if err := splitter.SetWeights(map[string]int{"stable": 70, "canary": 30}); err != nil {
+				t.Fatal(err)
+			}
+
+			selectGroup := func(key string) string {
+				req := httptest.NewRequest(http.MethodGet, "/leads", nil)
+				c := echo.New().NewContext(req, httptest.NewRecorder())
+				tt.request(req, c, key)
+				return splitter.SelectGroup(c)
+			}
+
+			canary := 0
+			for i := 0; i < clients; i++ {
+				key := fmt.Sprintf("10.0.%d.%d", i/250, i%250)
+				group := selectGroup(key)
+				for j := 0; j < 3; j++ {
+					if again := selectGroup(key); again != group {
+						t.Fatalf("client %s moved from %s to %s", key, group, again)
+					}
+				}
+				if group == "canary" {
+					canary++
+				}
+			}
+
+			// 30% de 2000 clientes, con margen para la dispersión del hash
+			if canary < clients*25/100 || canary > clients*35/100 {
+				t.Errorf("%d of %d clients in canary, want about 30%%", canary, clients)
+			}
+		})
+	}
+}
+
+// Los backends de cada grupo salen solo de ese grupo
+func TestTrafficSplitterNextBackendIn(t *testing.T) {
+	splitter := newTestSplitter(nil, "")
+
+	for group, backends := range map[string][]string{
+		"stable": {"http://stable-1", "http://stable-2"},
+		"canary": {"http://canary-1"},
+	} {
+		for i := 0; i < 4; i++ {
+			if backend := splitter.NextBackendIn(group); !slices.Contains(backends, backend) {
+				t.Errorf("NextBackendIn(%s) = %s, want one of %v", group, backend, backends)
+			}
+		}
+	}
+	if backend := splitter.NextBackendIn("beta"); backend != "" {
+		t.Errorf("NextBackendIn(unknown) = %q, want empty", backend)
+	}
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"api-gateway/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cada cuánto se revisa si cambió el archivo de usuarios
+const usersReloadCheckInterval = 10 * time.Second
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserNotFound       = errors.New("user not found or disabled")
+)
+
+// Usuario que puede pedir tokens al gateway
+type User struct {
+	ID       string
+	Username string
+	Role     string
+}
+
+// Origen de usuarios de /auth/token y /auth/refresh
+type UserSource interface {
+	// Usuario con esas credenciales; ErrInvalidCredentials si no existe, está
+	// deshabilitado o el password no coincide
+	Authenticate(ctx context.Context, username, password string) (*User, error)
+	// Usuario vigente al renovar tokens; ErrUserNotFound si ya no existe o está deshabilitado
+	Lookup(ctx context.Context, username string) (*User, error)
+}
+
+func newUserSource(cfg config.UserSourceConfig) (UserSource, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "file":
+		return newFileUserSource(cfg.File)
+	default:
+		return nil, fmt.Errorf("unknown user source: %s", cfg.Type)
+	}
+}
+
+// Usuario en el archivo de auth.users.file
+type fileUser struct {
+	ID           string `json:"id"`
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"` // bcrypt
+	Role         string `json:"role"`
+	Disabled     bool   `json:"disabled"`
+}
+
+// Usuarios desde un archivo JSON, que se relee cuando cambia su fecha de modificación
+type fileUserSource struct {
+	file      string
+	mutex     sync.RWMutex
+	users     map[string]fileUser
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// Hash para comparar cuando el usuario no existe, así la respuesta tarda lo
+// mismo y no revela qué usernames son válidos
+var missingUserHash = []byte("$2a$10$UA5jkh09IclBB6Nwn6PKHu02P6EG1R6wgtxPObp4DSoOv9dI0Fx6O")
+
+func newFileUserSource(file string) (*fileUserSource, error) {
+	s := &fileUserSource{file: file}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	users, err := loadUsersFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s.users = users
+	s.modTime = info.ModTime()
+	s.checkedAt = time.Now()
+	return s, nil
+}
+
+func (s *fileUserSource) Authenticate(ctx context.Context, username, password string) (*User, error) {
+	user, ok := s.user(username)
+	if !ok {
+		bcrypt.CompareHashAndPassword(missingUserHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	return user.toUser(), nil
+}
+
+func (s *fileUserSource) Lookup(ctx context.Context, username string) (*User, error) {
+	user, ok := s.user(username)
+	if !ok || user.Disabled {
+		return nil, ErrUserNotFound
+	}
+	return user.toUser(), nil
+}
+
+func (s *fileUserSource) user(username string) (fileUser, bool) {
+	s.reloadIfChanged()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	user, ok := s.users[username]
+	return user, ok
+}
+
+// Releer el archivo si cambió; si la carga falla se siguen usando los usuarios anteriores
+func (s *fileUserSource) reloadIfChanged() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if time.Since(s.checkedAt) < usersReloadCheckInterval {
+		return
+	}
+	s.checkedAt = time.Now()
+
+	info, err := os.Stat(s.file)
+	if err != nil {
+		fmt.Printf("⚠️  Users file reload error: %v\n", err)
+		return
+	}
+	if !info.ModTime().After(s.modTime) {
+		return
+	}
+
+	users, err := loadUsersFile(s.file)
+	if err != nil {
+		fmt.Printf("⚠️  Users file reload error, keeping previous users: %v\n", err)
+		return
+	}
+	s.users = users
+	s.modTime = info.ModTime()
+
+	fmt.Printf("👥 Users file reloaded: %d users\n", len(users))
+}
+
+func loadUsersFile(file string) (map[string]fileUser, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Users []fileUser `json:"users"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, fmt.Errorf("invalid users file %s: %w", file, err)
+	}
+
+	users := make(map[string]fileUser, len(document.Users))
+	for i, user := range document.Users {
+		if user.Username == "" {
+			return nil, fmt.Errorf("users[%d]: username is required", i)
+		}
+		if _, duplicate := users[user.Username]; duplicate {
+			return nil, fmt.Errorf("users[%d]: duplicate username %q", i, user.Username)
+		}
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("users[%d]: password_hash is not a bcrypt hash", i)
+		}
+		users[user.Username] = user
+	}
+	return users, nil
+}
+
+func (u fileUser) toUser() *User {
+	id := u.ID
+	if id == "" {
+		id = u.Username
+	}
+	return &User{ID: id, Username: u.Username, Role: u.Role}
+}
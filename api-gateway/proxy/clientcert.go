@@ -0,0 +1,26 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers con el certificado de cliente, solo si el listener HTTPS lo verificó
+// contra gateway.tls.client_ca_file. Son headers de identidad: los que envía
+// el cliente nunca se copian (ver identity.go).
+func setClientCertHeaders(req *http.Request, c echo.Context) {
+	state := c.Request().TLS
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return
+	}
+
+	cert := state.VerifiedChains[0][0]
+	fingerprint := sha256.Sum256(cert.Raw)
+	req.Header.Set("X-Client-Cert-Subject", cert.Subject.String())
+	req.Header.Set("X-Client-Cert-CN", cert.Subject.CommonName)
+	req.Header.Set("X-Client-Cert-Serial", cert.SerialNumber.Text(16))
+	req.Header.Set("X-Client-Cert-Fingerprint", hex.EncodeToString(fingerprint[:]))
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"api-gateway/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	circuitBreakerStateDesc = prometheus.NewDesc(
+		"api_gateway_circuit_breaker_state",
+		"Estado del circuit breaker por servicio (0=closed, 1=half_open, 2=open).",
+		[]string{"service"}, nil,
+	)
+	healthyBackendsDesc = prometheus.NewDesc(
+		"api_gateway_load_balancer_healthy_backends",
+		"Backends saludables en el load balancer de cada servicio.",
+		[]string{"service"}, nil,
+	)
+	backendGroupWeightDesc = prometheus.NewDesc(
+		"api_gateway_backend_group_weight",
+		"Porcentaje del tráfico asignado a cada grupo de backends.",
+		[]string{"service", "group"}, nil,
+	)
+	healthCheckUpDesc = prometheus.NewDesc(
+		"api_gateway_health_check_up",
+		"Resultado del último health check por servicio (1=healthy, 0=unhealthy).",
+		[]string{"service"}, nil,
+	)
+	healthCheckTimestampDesc = prometheus.NewDesc(
+		"api_gateway_health_check_last_timestamp_seconds",
+		"Momento del último health check por servicio.",
+		[]string{"service"}, nil,
+	)
+)
+
+// stateCollector lee el estado de circuit breakers, load balancers y
+// health checks del handler activo en el momento del scrape
+type stateCollector struct {
+	current func() *Handler
+}
+
+// Collector para registrar en metrics.Registry; recibe una función para
+// seguir al handler activo después de cada recarga de configuración
+func NewStateCollector(current func() *Handler) prometheus.Collector {
+	return &stateCollector{current: current}
+}
+
+func (sc *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- circuitBreakerStateDesc
+	ch <- healthyBackendsDesc
+	ch <- backendGroupWeightDesc
+	ch <- healthCheckUpDesc
+	ch <- healthCheckTimestampDesc
+}
+
+func (sc *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	h := sc.current()
+
+	for name, cb := range h.circuitBreakers.GetAllBreakers() {
+		ch <- prometheus.MustNewConstMetric(circuitBreakerStateDesc, prometheus.GaugeValue, float64(cb.State()), name)
+	}
+
+	for name, lb := range h.loadBalancers {
+		ch <- prometheus.MustNewConstMetric(healthyBackendsDesc, prometheus.GaugeValue, float64(len(lb.GetHealthyBackends())), name)
+
+		if splitter, ok := lb.(*middleware.TrafficSplitter); ok {
+			for group, weight := range splitter.Weights() {
+				ch <- prometheus.MustNewConstMetric(backendGroupWeightDesc, prometheus.GaugeValue, float64(weight), name, group)
+			}
+		}
+	}
+
+	for name, service := range h.healthChecker.GetAllServicesHealth() {
+		up := 0.0
+		if service.Healthy {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(healthCheckUpDesc, prometheus.GaugeValue, up, name)
+		if !service.LastCheck.IsZero() {
+			ch <- prometheus.MustNewConstMetric(healthCheckTimestampDesc, prometheus.GaugeValue, float64(service.LastCheck.Unix()), name)
+		}
+	}
+}
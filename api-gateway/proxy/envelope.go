@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"api-gateway/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Header con el que un cliente de la allowlist pide la respuesta del upstream sin envelope
+const rawResponseHeader = "X-Raw-Response"
+
+// Modo de envelope y allowlist de un servicio
+type envelopePolicy struct {
+	mode         string
+	rawAllowlist []*net.IPNet
+}
+
+func newEnvelopePolicy(cfg config.EnvelopeConfig) *envelopePolicy {
+	policy := &envelopePolicy{mode: cfg.Mode}
+	for _, entry := range cfg.RawAllowlist {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		// La configuración ya validó las entradas
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			policy.rawAllowlist = append(policy.rawAllowlist, network)
+		}
+	}
+	return policy
+}
+
+// Se compara con la IP de la conexión, no con X-Forwarded-For, para que no se pueda falsificar
+func (p *envelopePolicy) allowsRaw(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range p.rawAllowlist {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Modo de la request: X-Raw-Response si el cliente está en la allowlist,
+// después el de la ruta que la atiende y por último el del servicio
+func (h *Handler) envelopeMode(c echo.Context, service config.ServiceConfig) string {
+	policy := h.envelopes[service.Name]
+	if strings.EqualFold(c.Request().Header.Get(rawResponseHeader), "true") && policy.allowsRaw(c.Request()) {
+		return "passthrough"
+	}
+	if route := h.matchRoute(service.Name, c.Request()); route != nil && route.config.Envelope != "" {
+		return route.config.Envelope
+	}
+	return policy.mode
+}
+
+// Status de una respuesta con envelope: en modo wrap siempre 200,
+// porque el formato estándar informa el error en success/errorMessage
+func envelopeStatus(c echo.Context, status int) int {
+	if mode, _ := c.Get("envelope_mode").(string); mode == "wrap_preserve_status" || mode == "passthrough" {
+		return status
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+
+	"api-gateway/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Error en formato RFC 7807 (application/problem+json)
+type ProblemDetails struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail"`
+	Instance  string                 `json:"instance"`
+	Code      string                 `json:"code"`
+	Layer     string                 `json:"layer"`
+	RequestID string                 `json:"request_id"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Error handler central: convierte los errores de middlewares, del proxy y de
+// Echo (rutas inexistentes) al formato configurado en gateway.error_format
+func (h *Handler) HandleError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	// El cliente ya no está esperando la respuesta
+	if errors.Is(err, middleware.ErrClientCanceled) {
+		c.Response().WriteHeader(StatusClientClosedRequest)
+		return
+	}
+
+	gwErr := middleware.AsGatewayError(err)
+	if err := h.writeError(c, gwErr, gwErr.Status); err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// Escribir el error; status es el código HTTP en formato envelope, que en modo
+// wrap es 200. En formato problem siempre se usa el status real del error.
+func (h *Handler) writeError(c echo.Context, gwErr *middleware.GatewayError, status int) error {
+	h.addGatewayHeaders(c)
+	requestID := middleware.RequestID(c)
+
+	if h.config.Gateway.ErrorFormat == "problem" {
+		c.Response().Header().Set(echo.HeaderContentType, "application/problem+json")
+		if c.Request().Method == http.MethodHead {
+			return c.NoContent(gwErr.Status)
+		}
+		return c.JSON(gwErr.Status, ProblemDetails{
+			Type:      "about:blank",
+			Title:     http.StatusText(gwErr.Status),
+			Status:    gwErr.Status,
+			Detail:    gwErr.Error(),
+			Instance:  c.Request().URL.Path,
+			Code:      gwErr.Code,
+			Layer:     gwErr.Layer,
+			RequestID: requestID,
+			Details:   gwErr.Details,
+		})
+	}
+
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(status)
+	}
+
+	errorMsg := gwErr.Error()
+	return c.JSON(status, StandardResponse{
+		Data: ErrorData{
+			Code:      gwErr.Status,
+			Message:   gwErr.Message,
+			Path:      c.Request().URL.Path,
+			Method:    c.Request().Method,
+			ErrorCode: gwErr.Code,
+			Layer:     gwErr.Layer,
+			RequestID: requestID,
+			Details:   gwErr.Details,
+		},
+		Success:      false,
+		ErrorMessage: &errorMsg,
+	})
+}
@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
 	"api-gateway/config"
 	"api-gateway/health"
+	"api-gateway/metrics"
 	"api-gateway/middleware"
+	"api-gateway/storage"
 
 	"github.com/labstack/echo/v4"
 )
@@ -26,25 +32,41 @@ type StandardResponse struct {
 
 // Respuesta de error estándar
 type ErrorData struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Path    string `json:"path"`
-	Method  string `json:"method"`
+	Code      int                    `json:"code"`
+	Message   string                 `json:"message"`
+	Path      string                 `json:"path"`
+	Method    string                 `json:"method"`
+	ErrorCode string                 `json:"error_code,omitempty"` // código estable del gateway (RATE_LIMIT_EXCEEDED, ...)
+	Layer     string                 `json:"layer,omitempty"`      // auth, rate_limit, circuit_breaker, upstream o gateway
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
 }
 
 type Handler struct {
 	config          *config.Config
 	client          *http.Client
+	clients         map[string]*http.Client
+	transports      map[string]*upstreamTransport
 	healthChecker   *health.Checker
 	authMiddleware  *middleware.AuthMiddleware
 	circuitBreakers *middleware.CircuitBreakerManager
 	loadBalancers   map[string]middleware.LoadBalancer
+	caches          map[string]*middleware.ResponseCache
+	retryPolicies   map[string]*retryPolicy
+	hedgePolicies   map[string]*hedgePolicy
+	mirrorPolicies  map[string]*mirrorPolicy
+	rewriters       map[string]*pathRewriter
+	routes          map[string][]*routeRule
+	envelopes       map[string]*envelopePolicy
+	identityHeaders map[string]bool
+	store           storage.Store
 }
 
-func NewHandler(cfg *config.Config, healthChecker *health.Checker) *Handler {
-	// Cliente HTTP con configuración optimizada
+func NewHandler(cfg *config.Config, healthChecker *health.Checker, store storage.Store) (*Handler, error) {
+	// Cliente HTTP con configuración optimizada, usado para el tráfico mirror;
+	// los upstreams de cada servicio usan el cliente con su configuración TLS
+	// Sin timeout global: cada request usa el timeout de su servicio
 	client := &http.Client{
-		Timeout: 30 * time.Second,
 		Transport: &http.Transport{
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 100,
@@ -52,8 +74,23 @@ func NewHandler(cfg *config.Config, healthChecker *health.Checker) *Handler {
 		},
 	}
 
+	// Cliente de cada servicio, con su configuración TLS
+	clients := make(map[string]*http.Client)
+	transports := make(map[string]*upstreamTransport)
+	for _, service := range cfg.Gateway.Services {
+		transport, err := newUpstreamTransport(service.Name, service.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transports[service.Name] = transport
+		clients[service.Name] = &http.Client{Transport: transport}
+	}
+
 	// Inicializar middlewares
-	authMiddleware := middleware.NewAuthMiddleware(&cfg.Auth)
+	authMiddleware, err := middleware.NewAuthMiddleware(&cfg.Auth, store)
+	if err != nil {
+		return nil, err
+	}
 	circuitBreakers := middleware.NewCircuitBreakerManager()
 
 	// Crear load balancers para cada servicio
@@ -64,25 +101,105 @@ func NewHandler(cfg *config.Config, healthChecker *health.Checker) *Handler {
 		}
 	}
 
+	// Crear cache de respuestas para cada servicio que lo tenga habilitado
+	caches := make(map[string]*middleware.ResponseCache)
+	for _, service := range cfg.Gateway.Services {
+		if service.Cache.Enabled {
+			caches[service.Name] = middleware.NewResponseCache(service.Name, service.Cache, store)
+		}
+	}
+
+	// Rutas, envelope, reglas de rewrite y políticas de reintentos, hedging y mirror de cada servicio
+	routes := make(map[string][]*routeRule)
+	envelopes := make(map[string]*envelopePolicy)
+	rewriters := make(map[string]*pathRewriter)
+	retryPolicies := make(map[string]*retryPolicy)
+	hedgePolicies := make(map[string]*hedgePolicy)
+	mirrorPolicies := make(map[string]*mirrorPolicy)
+	for _, service := range cfg.Gateway.Services {
+		routes[service.Name] = newRouteRules(service.Routes)
+		envelopes[service.Name] = newEnvelopePolicy(service.Envelope)
+		rewriters[service.Name] = newPathRewriter(service)
+		retryPolicies[service.Name] = newRetryPolicy(service.Retry)
+		if service.Hedging.Enabled {
+			hedgePolicies[service.Name] = newHedgePolicy(service.Hedging)
+		}
+		if service.Mirror.Enabled {
+			mirrorPolicies[service.Name] = newMirrorPolicy(service.Mirror)
+		}
+	}
+
 	return &Handler{
 		config:          cfg,
 		client:          client,
+		clients:         clients,
+		transports:      transports,
 		healthChecker:   healthChecker,
 		authMiddleware:  authMiddleware,
 		circuitBreakers: circuitBreakers,
 		loadBalancers:   loadBalancers,
+		caches:          caches,
+		retryPolicies:   retryPolicies,
+		hedgePolicies:   hedgePolicies,
+		mirrorPolicies:  mirrorPolicies,
+		rewriters:       rewriters,
+		routes:          routes,
+		envelopes:       envelopes,
+		identityHeaders: newIdentityHeaders(cfg.Gateway.IdentityHeaders),
+		store:           store,
+	}, nil
+}
+
+// Middleware de autenticación configurado para este handler
+func (h *Handler) Auth() *middleware.AuthMiddleware {
+	return h.authMiddleware
+}
+
+// Conservar el estado de ejecución del handler anterior a una recarga: los
+// circuit breakers de los servicios que siguen configurados y los pesos de
+// grupos cambiados con la API, salvo que la recarga cambie esos grupos.
+// Se llama antes de ApplyMiddlewares.
+func (h *Handler) InheritState(previous *Handler) {
+	previousServices := make(map[string]config.ServiceConfig, len(previous.config.Gateway.Services))
+	for _, service := range previous.config.Gateway.Services {
+		previousServices[service.Name] = service
+	}
+
+	for _, service := range h.config.Gateway.Services {
+		before, exists := previousServices[service.Name]
+		if !exists {
+			continue
+		}
+
+		if cb, ok := previous.circuitBreakers.GetBreaker(service.Name); ok {
+			h.circuitBreakers.SetBreaker(service.Name, cb)
+		}
+
+		splitter, ok := h.TrafficSplitter(service.Name)
+		previousSplitter, previousOK := previous.TrafficSplitter(service.Name)
+		if ok && previousOK && reflect.DeepEqual(before.LoadBalancer.Groups, service.LoadBalancer.Groups) {
+			if err := splitter.SetWeights(previousSplitter.Weights()); err != nil {
+				fmt.Printf("⚠️  Could not keep backend group weights for %s: %v\n", service.Name, err)
+			}
+		}
 	}
 }
 
 func (h *Handler) ApplyMiddlewares(group *echo.Group, service config.ServiceConfig) {
+	// 0. Métricas (primero, para contar también las requests rechazadas)
+	group.Use(h.metricsMiddleware(service.Name))
+
+	// Los headers de identidad enviados por el cliente se descartan antes de los demás middlewares
+	group.Use(h.stripIdentityHeaders())
+
 	// 1. Autenticación (si está habilitada)
 	if h.config.Auth.Enabled {
-		group.Use(h.authMiddleware.JWTMiddleware())
+		group.Use(h.authMiddleware.ServiceJWTMiddleware(service.Auth))
 	}
 
 	// 2. Rate Limiting
 	if service.RateLimit.Enabled {
-		rateLimiter := middleware.NewRateLimiter(service.RateLimit)
+		rateLimiter := middleware.NewRateLimiter(service.Name, service.RateLimit, h.store)
 		group.Use(rateLimiter.RateLimitMiddleware())
 	}
 
@@ -94,50 +211,231 @@ func (h *Handler) ApplyMiddlewares(group *echo.Group, service config.ServiceConf
 }
 
 func (h *Handler) HandleProxy(service config.ServiceConfig) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		// Determinar URL de destino
-		targetURL, err := h.getTargetURL(service, c)
-		if err != nil {
-			return h.sendErrorResponse(c, http.StatusBadGateway, "Error determining target URL", err)
+	return func(c echo.Context) (err error) {
+		// Los handshakes de WebSocket no pasan por el cliente HTTP
+		if isWebSocketRequest(c.Request()) {
+			return h.handleWebSocket(c, service)
 		}
 
-		// Crear request proxy
-		proxyReq, err := h.createProxyRequest(c, targetURL, service)
-		if err != nil {
-			return h.sendErrorResponse(c, http.StatusInternalServerError, "Error creating proxy request", err)
+		// Formato de respuesta de esta request (wrap, wrap_preserve_status o passthrough)
+		mode := h.envelopeMode(c, service)
+		c.Set("envelope_mode", mode)
+
+		// Si el cliente se desconectó, no es un error del servicio
+		defer func() {
+			if c.Request().Context().Err() != nil {
+				err = h.clientCanceled(c, service)
+			}
+		}()
+
+		// Consultar cache antes de ir al servicio; las requests de una ruta
+		// alternativa o sin envelope no usan el cache porque la key no las distingue
+		cache, cacheable := h.caches[service.Name]
+		cacheable = cacheable && cache.IsCacheable(c.Request()) && h.matchRoute(service.Name, c.Request()) == nil
+		cacheable = cacheable && mode == service.Envelope.Mode
+		if cacheable {
+			if cached, hit := cache.Get(c.Request()); hit {
+				return h.writeCachedResponse(c, cached)
+			}
+			c.Response().Header().Set("X-Cache", "MISS")
 		}
 
-		// Ejecutar request
-		resp, err := h.client.Do(proxyReq)
+		// Reintentos solo si el método lo permite y el body se puede reenviar
+		policy := h.retryPolicies[service.Name]
+		policy.budget.deposit()
+		maxAttempts := policy.maxAttempts(c.Request())
+		// Copia al backend shadow según el muestreo
+		mirror, mirroring := h.mirrorPolicies[service.Name]
+		mirroring = mirroring && mirror.sample(c.Request())
+
+		var bufferedBody []byte
+		replay := false
+		if maxAttempts > 1 || mirroring {
+			body, replayable, err := bufferRequestBody(c.Request())
+			if err != nil {
+				return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadRequest, middleware.LayerGateway, middleware.CodeBadRequest, "Error reading request body").WithCause(err))
+			}
+			if !replayable {
+				maxAttempts = 1
+				mirroring = false
+			}
+			bufferedBody, replay = body, replayable
+		}
+
+		var shadow *mirrorRequest
+		if mirroring {
+			shadow = h.startMirror(c, service, mirror, bufferedBody)
+		}
+
+		// Hedging de lecturas lentas hacia otro backend
+		hedge, hedging := h.hedgePolicies[service.Name]
+		hedging = hedging && hedge.appliesTo(service, c.Request())
+		if hedging {
+			hedge.budget.deposit()
+		}
+
+		var attempt *upstreamAttempt
+		defer func() {
+			if attempt != nil {
+				attempt.release()
+			}
+		}()
+
+		tried := make(map[string]bool)
+		attempts := 0
+		start := time.Now()
+		for {
+			attempts++
+
+			// Determinar URL de destino, evitando backends que ya fallaron
+			backend, err := h.pickBackend(c, service, tried)
+			if err != nil {
+				return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerUpstream, middleware.CodeNoHealthyBackend, "Error determining target URL").WithCause(err))
+			}
+			tried[backend] = true
+
+			body := io.Reader(c.Request().Body)
+			if replay {
+				body = bytes.NewReader(bufferedBody)
+			}
+
+			// Crear request proxy; el timeout del servicio aplica por intento
+			attempt, err = h.prepareAttempt(c, service, backend, body)
+			if err != nil {
+				return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusInternalServerError, middleware.LayerGateway, middleware.CodeInternal, "Error creating proxy request").WithCause(err))
+			}
+
+			// Ejecutar request
+			if hedging {
+				attempt = h.executeHedged(c, service, hedge, attempt, tried)
+			} else {
+				attempt.execute(h.clients[service.Name])
+				h.markBackend(c, service, attempt)
+			}
+			if attempts >= maxAttempts || c.Request().Context().Err() != nil {
+				break
+			}
+
+			reason := policy.retryReason(attempt)
+			if reason == "" {
+				break
+			}
+			if !policy.budget.withdraw() {
+				metrics.RetryBudgetExhausted.WithLabelValues(service.Name).Inc()
+				break
+			}
+
+			h.recordUpstreamError(service, attempt)
+			metrics.UpstreamRetries.WithLabelValues(service.Name, reason).Inc()
+			fmt.Printf("[RETRY] %s %s attempt %d/%d failed (%s), retrying\n",
+				c.Request().Method, c.Request().URL.Path, attempts, maxAttempts, reason)
+
+			attempt.discard()
+			if !sleepContext(c.Request().Context(), policy.backoff(attempts)) {
+				return c.Request().Context().Err()
+			}
+		}
+
+		metrics.UpstreamAttempts.WithLabelValues(service.Name).Observe(float64(attempts))
+		c.Response().Header().Set("X-Upstream-Attempts", strconv.Itoa(attempts))
+		if shadow != nil {
+			shadow.complete(attempt, time.Since(start))
+		}
+
+		resp, err := attempt.resp, attempt.err
+		h.recordGroupResult(c, service, attempt)
 		if err != nil {
-			// Marcar backend como no saludable si hay load balancer
-			if lb, exists := h.loadBalancers[service.Name]; exists {
-				lb.MarkBackendDown(targetURL)
+			if c.Request().Context().Err() != nil {
+				return err
 			}
-			return h.sendErrorResponse(c, http.StatusBadGateway, "Service unavailable", err)
+			h.recordUpstreamError(service, attempt)
+			code := middleware.CodeUpstreamUnavailable
+			if errors.Is(context.Cause(attempt.ctx), context.DeadlineExceeded) {
+				code = middleware.CodeUpstreamTimeout
+				err = fmt.Errorf("timeout after %ds: %w", service.Timeout, context.DeadlineExceeded)
+			}
+			return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerUpstream, code, "Service unavailable").WithCause(err))
+		}
+		h.recordUpstreamError(service, attempt)
+
+		// SSE y otros streams largos: sin timeout y con flush por chunk
+		if isLongLivedStream(service, c, resp) {
+			attempt.deadline.Stop()
+			return h.streamResponse(c, resp, true)
 		}
-		defer resp.Body.Close()
 
-		// Marcar backend como saludable si hay load balancer
-		if lb, exists := h.loadBalancers[service.Name]; exists {
-			lb.MarkBackendUp(targetURL)
+		// Respuestas que no necesitan el formato estándar se envían en streaming
+		if h.shouldStream(service, c, resp) {
+			return h.streamResponse(c, resp, false)
 		}
 
 		// Leer y transformar response
-		return h.transformResponse(c, resp)
+		if !cacheable {
+			return h.transformResponse(c, resp)
+		}
+
+		// Capturar la respuesta transformada para almacenarla en cache
+		recorder := newResponseRecorder(c.Response().Writer)
+		c.Response().Writer = recorder
+		if err := h.transformResponse(c, resp); err != nil {
+			return err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			cache.Set(c.Request(), resp.Header, recorder.status, c.Response().Header(), recorder.body.Bytes())
+		}
+		return nil
 	}
 }
 
+func (h *Handler) writeCachedResponse(c echo.Context, cached *middleware.CachedResponse) error {
+	h.addGatewayHeaders(c)
+
+	header := c.Response().Header()
+	for name, values := range cached.Header {
+		header[name] = values
+	}
+	header.Set("X-Cache", "HIT")
+	header.Set("Age", fmt.Sprintf("%d", int(time.Since(cached.StoredAt).Seconds())))
+
+	c.Response().WriteHeader(cached.StatusCode)
+	_, err := c.Response().Write(cached.Body)
+	return err
+}
+
 func (h *Handler) getTargetURL(service config.ServiceConfig, c echo.Context) (string, error) {
-	var baseURL string
+	backend, err := h.pickBackend(c, service, nil)
+	if err != nil {
+		return "", err
+	}
+	return h.buildTargetURL(service, c, backend), nil
+}
+
+// Elegir el backend; con load balancer se evitan los ya intentados mientras haya alternativas
+func (h *Handler) pickBackend(c echo.Context, service config.ServiceConfig, tried map[string]bool) (string, error) {
+	// Las rutas por host, método, header o query tienen su propio backend
+	if route := h.matchRoute(service.Name, c.Request()); route != nil {
+		c.Response().Header().Set("X-Route", route.config.Name)
+		return route.config.BaseURL, nil
+	}
 
 	// Usar load balancer si está configurado
 	if lb, exists := h.loadBalancers[service.Name]; exists {
-		backend := lb.NextBackend()
+		next := lb.NextBackend
+
+		// Con grupos (canary) todos los intentos quedan en el grupo asignado
+		if splitter, ok := lb.(*middleware.TrafficSplitter); ok {
+			group := h.backendGroup(c, splitter)
+			next = func() string { return splitter.NextBackendIn(group) }
+		}
+
+		backend := next()
+		for i := len(lb.GetHealthyBackends()); i > 0 && tried[backend]; i-- {
+			backend = next()
+		}
 		if backend == "" {
 			return "", fmt.Errorf("no healthy backends available")
 		}
-		baseURL = backend
+		return backend, nil
 	} else {
 		// PARCHE TEMPORAL: Comentando verificación de health check
 		// TODO: Diagnosticar por qué el health checker falla
@@ -154,56 +452,43 @@ func (h *Handler) getTargetURL(service config.ServiceConfig, c echo.Context) (st
 				service.Name, service.HealthCheck.Enabled, isHealthy)
 		}
 		
-		baseURL = service.BaseURL
+		return service.BaseURL, nil
 	}
+}
 
-	// Construir URL completa - mejorado para NestJS
-	originalPath := c.Request().URL.Path
-	path := strings.TrimPrefix(originalPath, service.Prefix)
-
-	// Si el path queda vacío, significa que se accedió exactamente al prefix
-	if path == "" {
-		path = "/"
+// Grupo asignado a la request; se calcula una vez y se informa en X-Backend-Group
+func (h *Handler) backendGroup(c echo.Context, splitter *middleware.TrafficSplitter) string {
+	if group, ok := c.Get("backend_group").(string); ok {
+		return group
 	}
 
-	// Asegurar que el path empiece con /
-	if !strings.HasPrefix(path, "/") {
-		path = "/" + path
-	}
+	group := splitter.SelectGroup(c)
+	c.Set("backend_group", group)
+	c.Response().Header().Set("X-Backend-Group", group)
+	return group
+}
+
+func (h *Handler) buildTargetURL(service config.ServiceConfig, c echo.Context, baseURL string) string {
+	// Construir URL completa aplicando las reglas de rewrite del servicio
+	originalPath := c.Request().URL.Path
+	path, _ := h.rewriters[service.Name].rewrite(originalPath)
 
 	// Construir URL final
-	targetURL := baseURL + path
-	if c.Request().URL.RawQuery != "" {
-		targetURL += "?" + c.Request().URL.RawQuery
-	}
+	targetURL := upstreamURL(baseURL, path, c.Request().URL.RawQuery)
 
 	// Debug log mejorado
 	fmt.Printf("[PROXY] %s %s -> %s\n", c.Request().Method, originalPath, targetURL)
 
-	return targetURL, nil
+	return targetURL
 }
 
-func (h *Handler) createProxyRequest(c echo.Context, targetURL string, service config.ServiceConfig) (*http.Request, error) {
-	var body io.Reader
-
-	// Copiar body si existe
-	if c.Request().Body != nil {
-		bodyBytes, err := io.ReadAll(c.Request().Body)
-		if err != nil {
-			return nil, err
-		}
-		body = bytes.NewReader(bodyBytes)
-	}
-
-	// Crear nuevo request
-	req, err := http.NewRequest(c.Request().Method, targetURL, body)
+func (h *Handler) createProxyRequest(ctx context.Context, c echo.Context, targetURL string, body io.Reader) (*http.Request, error) {
+	// El body del cliente se envía en streaming al upstream (o desde memoria si hay reintentos)
+	req, err := http.NewRequestWithContext(ctx, c.Request().Method, targetURL, body)
 	if err != nil {
 		return nil, err
 	}
-
-	// Establecer timeout específico del servicio
-	ctx, _ := context.WithTimeout(context.Background(), time.Duration(service.Timeout)*time.Second)
-	req = req.WithContext(ctx)
+	req.ContentLength = c.Request().ContentLength
 
 	// Copiar headers importantes
 	h.copyRequestHeaders(c.Request().Header, req.Header)
@@ -218,7 +503,10 @@ func (h *Handler) transformResponse(c echo.Context, resp *http.Response) error {
 	// Leer el body de la respuesta
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return h.sendErrorResponse(c, http.StatusBadGateway, "Error reading service response", err)
+		if c.Request().Context().Err() != nil {
+			return err
+		}
+		return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerUpstream, middleware.CodeUpstreamBadResponse, "Error reading service response").WithCause(err))
 	}
 
 	// Agregar headers del gateway antes de procesar
@@ -236,8 +524,8 @@ func (h *Handler) transformResponse(c echo.Context, resp *http.Response) error {
 				fmt.Printf("[TRANSFORM] Standard format detected (HTTP %d), passing through: %s\n", resp.StatusCode, c.Request().URL.Path)
 				h.copyImportantHeaders(c, resp)
 				c.Response().Header().Set("Content-Type", "application/json")
-				// IMPORTANTE: Siempre devolver 200 porque nuestro formato estándar maneja errores internamente
-				c.Response().WriteHeader(http.StatusOK)
+				// En modo wrap siempre 200 porque nuestro formato estándar maneja errores internamente
+				c.Response().WriteHeader(envelopeStatus(c, resp.StatusCode))
 				_, err := c.Response().Write(bodyBytes)
 				return err
 			}
@@ -271,7 +559,7 @@ func (h *Handler) transformResponse(c echo.Context, resp *http.Response) error {
 		}
 
 		h.copyImportantHeaders(c, resp)
-		return c.JSON(http.StatusOK, standardResp)
+		return c.JSON(envelopeStatus(c, resp.StatusCode), standardResp)
 	}
 
 	// Para respuestas HTTP exitosas sin formato estándar, aplicar transformación
@@ -298,29 +586,12 @@ func (h *Handler) transformResponse(c echo.Context, resp *http.Response) error {
 	h.copyImportantHeaders(c, resp)
 
 	// Enviar respuesta transformada
-	return c.JSON(http.StatusOK, standardResp)
+	return c.JSON(envelopeStatus(c, resp.StatusCode), standardResp)
 }
 
-func (h *Handler) sendErrorResponse(c echo.Context, statusCode int, message string, err error) error {
-	errorMsg := message
-	if err != nil {
-		errorMsg = fmt.Sprintf("%s: %s", message, err.Error())
-	}
-
-	h.addGatewayHeaders(c)
-
-	errorResp := StandardResponse{
-		Data: ErrorData{
-			Code:    statusCode,
-			Message: message,
-			Path:    c.Request().URL.Path,
-			Method:  c.Request().Method,
-		},
-		Success:      false,
-		ErrorMessage: &errorMsg,
-	}
-
-	return c.JSON(http.StatusOK, errorResp)
+// Error generado por el proxy; en formato envelope respeta el modo del servicio
+func (h *Handler) sendErrorResponse(c echo.Context, gwErr *middleware.GatewayError) error {
+	return h.writeError(c, gwErr, envelopeStatus(c, gwErr.Status))
 }
 
 // Nueva función simplificada para generar mensajes de error sin duplicar contenido
@@ -422,6 +693,11 @@ func (h *Handler) shouldCopyHeader(header string) bool {
 		"host":                true, // Se establecerá automáticamente
 	}
 
+	// Los headers de identidad los emite el gateway; el cliente no puede enviarlos
+	if h.isIdentityHeader(header) {
+		return false
+	}
+
 	return !skipHeaders[strings.ToLower(header)]
 }
 
@@ -434,18 +710,10 @@ func (h *Handler) addProxyHeaders(req *http.Request, c echo.Context) {
 	// Headers del gateway
 	req.Header.Set("X-Gateway", "api-gateway")
 	req.Header.Set("X-Gateway-Version", "1.0.0")
-	req.Header.Set("X-Request-ID", generateRequestID())
+	req.Header.Set("X-Request-ID", middleware.RequestID(c))
 
-	// Información del usuario si está autenticado
-	if userID, ok := c.Get("user_id").(string); ok && userID != "" {
-		req.Header.Set("X-User-ID", userID)
-	}
-	if username, ok := c.Get("username").(string); ok && username != "" {
-		req.Header.Set("X-Username", username)
-	}
-	if role, ok := c.Get("role").(string); ok && role != "" {
-		req.Header.Set("X-User-Role", role)
-	}
+	// Identidad del usuario y del certificado de cliente, solo si fueron verificadas
+	setIdentityHeaders(req, c)
 }
 
 func (h *Handler) loggingMiddleware(serviceName string) echo.MiddlewareFunc {
@@ -454,7 +722,7 @@ func (h *Handler) loggingMiddleware(serviceName string) echo.MiddlewareFunc {
 			start := time.Now()
 
 			// Generar request ID si no existe
-			requestID := c.Request().Header.Get("X-Request-ID")
+			requestID := middleware.RequestID(c)
 			if requestID == "" {
 				requestID = generateRequestID()
 			}
@@ -492,6 +760,96 @@ func (h *Handler) loggingMiddleware(serviceName string) echo.MiddlewareFunc {
 	}
 }
 
+func (h *Handler) metricsMiddleware(serviceName string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+
+			// Los errores de los middlewares aún no se escribieron en la respuesta
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = middleware.AsGatewayError(err).Status
+				if errors.Is(err, middleware.ErrClientCanceled) {
+					status = StatusClientClosedRequest
+				}
+			}
+
+			labels := []string{serviceName, c.Path(), c.Request().Method, strconv.Itoa(status)}
+			metrics.RequestsTotal.WithLabelValues(labels...).Inc()
+			metrics.RequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// Marcar en el load balancer el backend del intento: no saludable si falló
+// la conexión, saludable si respondió. Las cancelaciones del cliente no cuentan.
+func (h *Handler) markBackend(c echo.Context, service config.ServiceConfig, attempt *upstreamAttempt) {
+	lb, exists := h.loadBalancers[service.Name]
+	if !exists || c.Request().Context().Err() != nil {
+		return
+	}
+	if attempt.err != nil {
+		lb.MarkBackendDown(attempt.backend)
+	} else {
+		lb.MarkBackendUp(attempt.backend)
+	}
+}
+
+// Registrar errores de transporte y respuestas 5xx de un intento
+func (h *Handler) recordUpstreamError(service config.ServiceConfig, attempt *upstreamAttempt) {
+	if attempt.err != nil {
+		metrics.UpstreamErrors.WithLabelValues(service.Name, upstreamErrorType(attempt.ctx, attempt.err)).Inc()
+	} else if attempt.resp.StatusCode >= 500 {
+		metrics.UpstreamErrors.WithLabelValues(service.Name, "http_5xx").Inc()
+	}
+}
+
+// Resultado por grupo de backends, para comparar la tasa de error del canary
+func (h *Handler) recordGroupResult(c echo.Context, service config.ServiceConfig, attempt *upstreamAttempt) {
+	group, ok := c.Get("backend_group").(string)
+	if !ok || c.Request().Context().Err() != nil {
+		return
+	}
+
+	result := "success"
+	if !attempt.succeeded() {
+		result = "error"
+	}
+	metrics.BackendGroupRequests.WithLabelValues(service.Name, group, result).Inc()
+}
+
+// Grupos de backends (canary) del servicio, si los tiene
+func (h *Handler) TrafficSplitter(serviceName string) (*middleware.TrafficSplitter, bool) {
+	splitter, ok := h.loadBalancers[serviceName].(*middleware.TrafficSplitter)
+	return splitter, ok
+}
+
+// Status usado en logs y métricas cuando el cliente cierra la conexión (convención de nginx)
+const StatusClientClosedRequest = 499
+
+func (h *Handler) clientCanceled(c echo.Context, service config.ServiceConfig) error {
+	metrics.ClientCancellations.WithLabelValues(service.Name).Inc()
+	fmt.Printf("[PROXY] %s %s canceled by client\n", c.Request().Method, c.Request().URL.Path)
+
+	if !c.Response().Committed {
+		c.Response().WriteHeader(StatusClientClosedRequest)
+	}
+	return middleware.ErrClientCanceled
+}
+
+// Clasificar errores de transporte para las métricas
+func upstreamErrorType(ctx context.Context, err error) string {
+	var netErr net.Error
+	if errors.Is(context.Cause(ctx), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "connection"
+}
+
 // Verificar si una respuesta ya tiene el formato estándar
 func (h *Handler) isStandardFormat(response map[string]interface{}) bool {
 	// Verificar que tenga los campos requeridos: data, success, errorMessage
@@ -552,5 +910,12 @@ func (h *Handler) GetMetrics() map[string]interface{} {
 	}
 	metrics["load_balancers"] = lbMetrics
 
+	// Métricas de cache
+	cacheMetrics := make(map[string]interface{})
+	for name, cache := range h.caches {
+		cacheMetrics[name] = cache.Stats()
+	}
+	metrics["cache"] = cacheMetrics
+
 	return metrics
 }
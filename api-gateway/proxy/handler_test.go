@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/health"
+	"api-gateway/storage"
+	"api-gateway/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Gateway con los servicios de la config, armado igual que en main.go
+func newTestGateway(t *testing.T, cfg *config.Config) (*echo.Echo, *Handler) {
+	t.Helper()
+
+	handler, err := NewHandler(cfg, health.NewChecker(), storage.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = handler.HandleError
+	for _, service := range cfg.Gateway.Services {
+		group := e.Group(service.Prefix)
+		handler.ApplyMiddlewares(group, service)
+		group.Any("/*", handler.HandleProxy(service))
+	}
+	return e, handler
+}
+
+// URL de un backend que rechaza las conexiones
+func refusedBackendURL(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String()
+	listener.Close()
+	return url
+}
+
+// Un backend que rechaza conexiones queda fuera del load balancer, con o sin
+// reintentos, y las requests siguientes van solo al backend sano
+func TestLoadBalancerMarksRefusedBackendDown(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer healthy.Close()
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		statuses    []int
+	}{
+		// Round robin empieza por el backend caído
+		{"without retries", 1, []int{http.StatusBadGateway, http.StatusOK, http.StatusOK, http.StatusOK}},
+		{"retried on the healthy backend", 3, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refused := refusedBackendURL(t)
+			cfg := testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {"services": [{
+    "name": "leads", "prefix": "/leads", "base_url": %[2]q, "timeout": 5,
+    "load_balancer": {"enabled": true, "strategy": "round_robin", "backends": [%[1]q, %[2]q]},
+    "retry": {"max_attempts": %[3]d, "initial_backoff_ms": 1},
+    "envelope": {"mode": "wrap_preserve_status"}
+  }]}
+}`, refused, healthy.URL, tt.maxAttempts))
+			e, handler := newTestGateway(t, cfg)
+
+			for i, want := range tt.statuses {
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/leads/items", nil))
+				if rec.Code != want {
+					t.Errorf("request %d: status = %d, want %d (%s)", i+1, rec.Code, want, rec.Body.String())
+				}
+			}
+
+			got := handler.loadBalancers["leads"].GetHealthyBackends()
+			if !slices.Equal(got, []string{healthy.URL}) {
+				t.Errorf("healthy backends = %v, want only %s", got, healthy.URL)
+			}
+		})
+	}
+}
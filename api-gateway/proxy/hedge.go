@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Política de hedging de un servicio. El cap usa el mismo mecanismo que el
+// budget de reintentos: cada request elegible deposita max_percent/100 tokens
+// y cada hedge consume uno.
+type hedgePolicy struct {
+	config config.HedgingConfig
+	budget *retryBudget
+}
+
+func newHedgePolicy(cfg config.HedgingConfig) *hedgePolicy {
+	return &hedgePolicy{
+		config: cfg,
+		budget: newRetryBudget(cfg.MaxPercent/100, 0),
+	}
+}
+
+// Solo se hace hedging de lecturas sin body, en los paths configurados
+func (p *hedgePolicy) appliesTo(service config.ServiceConfig, req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if len(p.config.Paths) == 0 {
+		return true
+	}
+	return matchesServicePath(service, req.URL.Path, p.config.Paths)
+}
+
+// Ejecutar el intento y, si no responde dentro de delay_ms, enviar la misma
+// request a otro backend. Se usa la primera respuesta exitosa y se cancela la otra.
+func (h *Handler) executeHedged(c echo.Context, service config.ServiceConfig, policy *hedgePolicy, primary *upstreamAttempt, tried map[string]bool) *upstreamAttempt {
+	results := make(chan *upstreamAttempt, 2)
+	var launched []*upstreamAttempt
+	launch := func(attempt *upstreamAttempt) {
+		launched = append(launched, attempt)
+		go func() {
+			attempt.execute(h.clients[service.Name])
+			results <- attempt
+		}()
+	}
+
+	launch(primary)
+	pending := 1
+
+	timer := time.NewTimer(time.Duration(policy.config.DelayMs) * time.Millisecond)
+	defer timer.Stop()
+
+	var hedge, failed *upstreamAttempt
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			hedge = h.startHedge(c, service, policy, tried)
+			if hedge != nil {
+				launch(hedge)
+				pending++
+			}
+		case attempt := <-results:
+			pending--
+			h.markBackend(c, service, attempt)
+			if attempt.succeeded() {
+				// Cancelar ya la request que sigue en curso y liberarla cuando termine
+				for _, other := range launched {
+					if other != attempt {
+						other.cancel(nil)
+					}
+				}
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						(<-results).release()
+					}
+				}(pending)
+
+				if attempt == hedge {
+					metrics.HedgeWins.WithLabelValues(service.Name).Inc()
+					fmt.Printf("[HEDGE] %s %s answered by %s\n", c.Request().Method, c.Request().URL.Path, attempt.backend)
+				}
+				if failed != nil {
+					failed.release()
+				}
+				return attempt
+			}
+
+			// Conservar solo la última falla
+			if failed != nil {
+				failed.release()
+			}
+			failed = attempt
+		}
+	}
+	return failed
+}
+
+func (h *Handler) startHedge(c echo.Context, service config.ServiceConfig, policy *hedgePolicy, tried map[string]bool) *upstreamAttempt {
+	backend, err := h.pickBackend(c, service, tried)
+	if err != nil || tried[backend] {
+		return nil
+	}
+	if !policy.budget.withdraw() {
+		metrics.HedgeCapped.WithLabelValues(service.Name).Inc()
+		return nil
+	}
+
+	attempt, err := h.prepareAttempt(c, service, backend, http.NoBody)
+	if err != nil {
+		return nil
+	}
+	tried[backend] = true
+
+	metrics.HedgeRequests.WithLabelValues(service.Name).Inc()
+	return attempt
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/testutil"
+)
+
+// Cuando el hedge responde primero, la request al backend lento se cancela
+// enseguida en lugar de seguir abierta hasta el timeout del servicio
+func TestHedgeCancelsSlowerAttempt(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	canceled := make(chan time.Duration, 1)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		select {
+		case <-r.Context().Done():
+			canceled <- time.Since(start)
+		case <-time.After(5 * time.Second):
+			w.Write([]byte(`{"backend":"slow"}`))
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"backend":"fast"}`))
+	}))
+	defer fast.Close()
+
+	// Round robin empieza por el backend lento; max_percent 100 permite el hedge
+	// desde la primera request
+	cfg := testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {"services": [{
+    "name": "leads", "prefix": "/leads", "base_url": %[1]q, "timeout": 10,
+    "load_balancer": {"enabled": true, "strategy": "round_robin", "backends": [%[1]q, %[2]q]},
+    "retry": {"max_attempts": 1},
+    "hedging": {"enabled": true, "delay_ms": 20, "max_percent": 100}
+  }]}
+}`, slow.URL, fast.URL))
+	e, _ := newTestGateway(t, cfg)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/leads/items", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "fast") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case elapsed := <-canceled:
+		if elapsed > time.Second {
+			t.Errorf("slow attempt canceled after %v", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("slow attempt still running 2s after the hedge answered")
+	}
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers de identidad que emite el gateway: los de usuario a partir del JWT
+// verificado y los del certificado de cliente verificado por el listener HTTPS
+var issuedIdentityHeaders = []string{
+	"X-User-ID",
+	"X-Username",
+	"X-User-Role",
+	"X-Client-Cert-Subject",
+	"X-Client-Cert-CN",
+	"X-Client-Cert-Serial",
+	"X-Client-Cert-Fingerprint",
+}
+
+// Headers propios del gateway: los emitidos más gateway.identity_headers.
+// Nunca se copian de la request del cliente.
+func newIdentityHeaders(configured []string) map[string]bool {
+	headers := make(map[string]bool)
+	for _, header := range issuedIdentityHeaders {
+		headers[http.CanonicalHeaderKey(header)] = true
+	}
+	for _, header := range configured {
+		headers[http.CanonicalHeaderKey(header)] = true
+	}
+	return headers
+}
+
+func (h *Handler) isIdentityHeader(header string) bool {
+	return h.identityHeaders[http.CanonicalHeaderKey(header)]
+}
+
+// Eliminar los headers de identidad de la request entrante, para que ni el ruteo,
+// ni el load balancer, ni los logs usen valores enviados por el cliente
+func (h *Handler) stripIdentityHeaders() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for header := range h.identityHeaders {
+				c.Request().Header.Del(header)
+			}
+			return next(c)
+		}
+	}
+}
+
+// Volver a emitir los headers de identidad solo desde el contexto verificado
+func setIdentityHeaders(req *http.Request, c echo.Context) {
+	// Información del usuario si está autenticado con JWT
+	if userID, ok := c.Get("user_id").(string); ok && userID != "" {
+		req.Header.Set("X-User-ID", userID)
+	}
+	if username, ok := c.Get("username").(string); ok && username != "" {
+		req.Header.Set("X-Username", username)
+	}
+	if role, ok := c.Get("role").(string); ok && role != "" {
+		req.Header.Set("X-User-Role", role)
+	}
+
+	// Certificado de cliente verificado en el listener HTTPS
+	setClientCertHeaders(req, c)
+}
@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-gateway/config"
+	"api-gateway/middleware"
+	"api-gateway/storage"
+	"api-gateway/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers que el upstream de prueba devuelve tal como los recibió
+var observedIdentityHeaders = []string{
+	"X-User-ID",
+	"X-Username",
+	"X-User-Role",
+	"X-Tenant-ID",
+	"X-Client-Cert-CN",
+	"X-Client-Cert-Subject",
+	"X-Route",
+}
+
+// Un cliente no puede hacer llegar al upstream X-User-ID, X-User-Role,
+// X-Client-Cert-* ni los headers de gateway.identity_headers, con o sin autenticación
+func TestIdentityHeadersCannotBeSpoofed(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received := make(map[string][]string)
+		for _, header := range observedIdentityHeaders {
+			if values := r.Header.Values(header); len(values) > 0 {
+				received[header] = values
+			}
+		}
+		if strings.HasPrefix(r.URL.Path, "/admin-backend") {
+			received["X-Route"] = []string{"admin"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(received)
+	}))
+	defer upstream.Close()
+
+	// /open sin autenticación y /secure con JWT
+	open, _ := newIdentityTestGateway(t, upstream.URL, false)
+	secure, cfg := newIdentityTestGateway(t, upstream.URL, true)
+	gateways := map[string]*echo.Echo{"/open": open, "/secure": secure}
+
+	auth, err := middleware.NewAuthMiddleware(&cfg.Auth, storage.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.GenerateToken("u-42", "maria", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spoofed := map[string][]string{
+		"X-User-ID":             {"admin"},
+		"x-user-role":           {"admin"},
+		"X-Username":            {"root"},
+		"X-Tenant-ID":           {"otro-tenant"},
+		"X-Client-Cert-CN":      {"gateway"},
+		"X-Client-Cert-Subject": {"CN=gateway"},
+	}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		token    bool
+		headers  map[string][]string
+		expected map[string][]string // headers esperados en el upstream; el resto debe faltar
+	}{
+		{
+			name:     "without auth all identity headers are stripped",
+			prefix:   "/open",
+			headers:  spoofed,
+			expected: map[string][]string{},
+		},
+		{
+			name:     "without auth X-User-Role does not select the admin route",
+			prefix:   "/open",
+			headers:  map[string][]string{"X-User-Role": {"admin"}},
+			expected: map[string][]string{},
+		},
+		{
+			name:    "with a JWT only the verified claims reach the upstream",
+			prefix:  "/secure",
+			token:   true,
+			headers: spoofed,
+			expected: map[string][]string{
+				"X-User-ID":   {"u-42"},
+				"X-Username":  {"maria"},
+				"X-User-Role": {"user"},
+			},
+		},
+	}
+
+	testutil.SilenceStdout(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.prefix+"/perfil", nil)
+			for header, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(header, value)
+				}
+			}
+			if tt.token {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+
+			rec := httptest.NewRecorder()
+			gateways[tt.prefix].ServeHTTP(rec, req)
+
+			var response struct {
+				Data    map[string][]string `json:"data"`
+				Success bool                `json:"success"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || !response.Success {
+				t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+			}
+			for _, header := range observedIdentityHeaders {
+				got := strings.Join(response.Data[header], ",")
+				want := strings.Join(tt.expected[header], ",")
+				if got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
+
+// Gateway con un servicio en /open y otro en /secure, armado igual que en main.go
+func newIdentityTestGateway(t *testing.T, upstreamURL string, authEnabled bool) (*echo.Echo, *config.Config) {
+	t.Helper()
+
+	cfg := testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {
+    "identity_headers": ["X-Tenant-ID"],
+    "services": [
+      {"name": "open", "prefix": "/open", "base_url": %[1]q, "timeout": 5,
+       "routes": [{"name": "admin", "match": {"headers": {"X-User-Role": "admin"}}, "base_url": %[2]q}]},
+      {"name": "secure", "prefix": "/secure", "base_url": %[1]q, "timeout": 5}
+    ]
+  },
+  "auth": {"enabled": %[3]t, "jwt_secret": "identity-check", "token_expiry_hours": 1, "refresh_expiry_hours": 1}
+}`, upstreamURL, upstreamURL+"/admin-backend", authEnabled))
+
+	e, _ := newTestGateway(t, cfg)
+	return e, cfg
+}
@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Política de shadowing de un servicio
+type mirrorPolicy struct {
+	config  config.MirrorConfig
+	methods map[string]bool
+}
+
+func newMirrorPolicy(cfg config.MirrorConfig) *mirrorPolicy {
+	policy := &mirrorPolicy{
+		config:  cfg,
+		methods: make(map[string]bool),
+	}
+	for _, method := range cfg.Methods {
+		policy.methods[method] = true
+	}
+	return policy
+}
+
+// Decidir si la request se copia al shadow según el método y el muestreo
+func (p *mirrorPolicy) sample(req *http.Request) bool {
+	return p.methods[req.Method] && rand.Float64()*100 < p.config.SamplePercent
+}
+
+// Resultado de la request principal, para compararlo con el shadow
+type mirrorResult struct {
+	status  int // 0 si hubo error de transporte
+	latency time.Duration
+}
+
+// Copia de una request enviada al shadow en segundo plano
+type mirrorRequest struct {
+	primary chan mirrorResult
+}
+
+// Enviar la copia al shadow. La request se arma acá porque el contexto de echo
+// no puede usarse desde otra goroutine; la respuesta del shadow se descarta.
+func (h *Handler) startMirror(c echo.Context, service config.ServiceConfig, policy *mirrorPolicy, body []byte) *mirrorRequest {
+	timeout := time.Duration(policy.config.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	targetURL := h.buildTargetURL(service, c, policy.config.URL)
+	req, err := http.NewRequestWithContext(ctx, c.Request().Method, targetURL, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil
+	}
+	h.copyRequestHeaders(c.Request().Header, req.Header)
+	h.addProxyHeaders(req, c)
+	req.Header.Set("X-Shadow-Request", "true")
+
+	mirror := &mirrorRequest{primary: make(chan mirrorResult, 1)}
+	method, path := c.Request().Method, c.Request().URL.Path
+
+	go func() {
+		defer cancel()
+
+		start := time.Now()
+		resp, err := h.client.Do(req)
+		latency := time.Since(start)
+
+		shadowStatus := 0
+		if err == nil {
+			shadowStatus = resp.StatusCode
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		// Esperar el resultado de la request principal
+		var primary mirrorResult
+		select {
+		case primary = <-mirror.primary:
+		case <-time.After(timeout):
+			return
+		}
+
+		h.recordMirror(service, policy, method, path, primary, mirrorResult{status: shadowStatus, latency: latency}, err)
+	}()
+
+	return mirror
+}
+
+// Informar el resultado de la request principal
+func (m *mirrorRequest) complete(attempt *upstreamAttempt, latency time.Duration) {
+	result := mirrorResult{latency: latency}
+	if attempt.err == nil {
+		result.status = attempt.resp.StatusCode
+	}
+	m.primary <- result
+}
+
+func (h *Handler) recordMirror(service config.ServiceConfig, policy *mirrorPolicy, method, path string, primary, shadow mirrorResult, shadowErr error) {
+	metrics.MirrorLatency.WithLabelValues(service.Name, "primary").Observe(primary.latency.Seconds())
+	metrics.MirrorLatency.WithLabelValues(service.Name, "shadow").Observe(shadow.latency.Seconds())
+
+	result := "match"
+	switch {
+	case shadowErr != nil:
+		result = "shadow_error"
+	case shadow.status != primary.status:
+		result = "status_mismatch"
+	}
+	metrics.MirrorRequests.WithLabelValues(service.Name, result).Inc()
+
+	if policy.config.LogDiffs && result != "match" {
+		shadowStatus := strconv.Itoa(shadow.status)
+		if shadowErr != nil {
+			shadowStatus = shadowErr.Error()
+		}
+		fmt.Printf("[MIRROR] %s %s %s: primary=%d (%v) shadow=%s (%v)\n",
+			service.Name, method, path, primary.status, primary.latency, shadowStatus, shadow.latency)
+	}
+}
@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"api-gateway/config"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// Tamaño máximo del body que se guarda en memoria para poder reintentar
+const maxRetryBodyBytes = 1 << 20
+
+// Cantidad de requests cuyo depósito puede acumular el budget
+const retryBudgetWindow = 100
+
+// Política de reintentos de un servicio
+type retryPolicy struct {
+	config   config.RetryConfig
+	methods  map[string]bool
+	statuses map[int]bool
+	errors   map[string]bool
+	budget   *retryBudget
+}
+
+func newRetryPolicy(cfg config.RetryConfig) *retryPolicy {
+	policy := &retryPolicy{
+		config:   cfg,
+		methods:  make(map[string]bool),
+		statuses: make(map[int]bool),
+		errors:   make(map[string]bool),
+		budget:   newRetryBudget(cfg.BudgetPercent/100, cfg.MinRetriesPerSecond),
+	}
+	for _, method := range cfg.Methods {
+		policy.methods[method] = true
+	}
+	for _, status := range cfg.RetryOnStatus {
+		policy.statuses[status] = true
+	}
+	for _, kind := range cfg.RetryOnErrors {
+		policy.errors[kind] = true
+	}
+	return policy
+}
+
+// Intentos permitidos para la request según su método
+func (p *retryPolicy) maxAttempts(req *http.Request) int {
+	if !p.methods[req.Method] {
+		return 1
+	}
+	return p.config.MaxAttempts
+}
+
+// Motivo por el que se reintenta el intento, o "" si no corresponde
+func (p *retryPolicy) retryReason(attempt *upstreamAttempt) string {
+	if attempt.err != nil {
+		kind := upstreamErrorType(attempt.ctx, attempt.err)
+		if p.errors[kind] {
+			return kind
+		}
+		return ""
+	}
+	if p.statuses[attempt.resp.StatusCode] {
+		return fmt.Sprintf("status_%d", attempt.resp.StatusCode)
+	}
+	return ""
+}
+
+// Backoff exponencial con jitter completo
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	delay := time.Duration(p.config.InitialBackoffMs) * time.Millisecond
+	maxDelay := time.Duration(p.config.MaxBackoffMs) * time.Millisecond
+	for i := 1; i < retry && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// Budget de reintentos: cada request deposita budget_percent/100 tokens y
+// cada reintento consume uno, así los reintentos no superan ese porcentaje
+// del tráfico. min_retries_per_second permite reintentar con poco tráfico.
+type retryBudget struct {
+	mutex   sync.Mutex
+	ratio   float64
+	balance float64
+	reserve *rate.Limiter
+}
+
+func newRetryBudget(ratio float64, minPerSecond int) *retryBudget {
+	return &retryBudget{
+		ratio:   ratio,
+		reserve: rate.NewLimiter(rate.Limit(minPerSecond), minPerSecond),
+	}
+}
+
+func (b *retryBudget) deposit() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.balance += b.ratio
+	if limit := b.ratio * retryBudgetWindow; b.balance > limit {
+		b.balance = limit
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mutex.Lock()
+	if b.balance >= 1 {
+		b.balance--
+		b.mutex.Unlock()
+		return true
+	}
+	b.mutex.Unlock()
+
+	return b.reserve.Allow()
+}
+
+// Un intento hacia el upstream, con su propio timeout
+type upstreamAttempt struct {
+	backend   string
+	targetURL string
+	req       *http.Request
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	deadline  *time.Timer
+	resp      *http.Response
+	err       error
+}
+
+func newUpstreamAttempt(parent context.Context, backend string, timeout time.Duration) *upstreamAttempt {
+	ctx, cancel := context.WithCancelCause(parent)
+	return &upstreamAttempt{
+		backend:  backend,
+		ctx:      ctx,
+		cancel:   cancel,
+		deadline: time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) }),
+	}
+}
+
+// Preparar un intento hacia el backend sin ejecutarlo
+func (h *Handler) prepareAttempt(c echo.Context, service config.ServiceConfig, backend string, body io.Reader) (*upstreamAttempt, error) {
+	attempt := newUpstreamAttempt(c.Request().Context(), backend, time.Duration(service.Timeout)*time.Second)
+	attempt.targetURL = h.buildTargetURL(service, c, backend)
+
+	req, err := h.createProxyRequest(attempt.ctx, c, attempt.targetURL, body)
+	if err != nil {
+		attempt.release()
+		return nil, err
+	}
+	attempt.req = req
+	return attempt, nil
+}
+
+func (a *upstreamAttempt) execute(client *http.Client) {
+	a.resp, a.err = client.Do(a.req)
+}
+
+// Sin error de transporte ni 5xx
+func (a *upstreamAttempt) succeeded() bool {
+	return a.err == nil && a.resp.StatusCode < 500
+}
+
+// Descartar la respuesta de un intento fallido antes de reintentar
+func (a *upstreamAttempt) discard() {
+	if a.resp != nil {
+		// Leer un poco del body permite reutilizar la conexión
+		io.CopyN(io.Discard, a.resp.Body, 4096)
+	}
+	a.release()
+}
+
+func (a *upstreamAttempt) release() {
+	a.deadline.Stop()
+	if a.resp != nil {
+		a.resp.Body.Close()
+	}
+	a.cancel(nil)
+}
+
+// Leer el body en memoria para poder reenviarlo en cada intento.
+// Devuelve false si es demasiado grande o de largo desconocido.
+func bufferRequestBody(req *http.Request) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil, true, nil
+	}
+	if req.ContentLength < 0 || req.ContentLength > maxRetryBodyBytes {
+		return nil, false, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, req.ContentLength))
+	if err != nil {
+		return nil, false, err
+	}
+	return body, true, nil
+}
+
+// Esperar el backoff salvo que el cliente cancele antes
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/testutil"
+)
+
+func testRetryConfig() config.RetryConfig {
+	return config.RetryConfig{
+		MaxAttempts:      3,
+		Methods:          []string{"GET", "PUT"},
+		RetryOnStatus:    []int{502, 503},
+		RetryOnErrors:    []string{"connection"},
+		InitialBackoffMs: 10,
+		MaxBackoffMs:     40,
+		BudgetPercent:    50,
+	}
+}
+
+func TestRetryPolicyMaxAttempts(t *testing.T) {
+	policy := newRetryPolicy(testRetryConfig())
+
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{http.MethodGet, 3},
+		{http.MethodPut, 3},
+		{http.MethodPost, 1},
+		{http.MethodDelete, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/leads", nil)
+			if got := policy.maxAttempts(req); got != tt.want {
+				t.Errorf("maxAttempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRetryReason(t *testing.T) {
+	policy := newRetryPolicy(testRetryConfig())
+
+	// Intento terminado con el error o el status dados
+	attemptWith := func(status int, err error, cause error) *upstreamAttempt {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		if cause != nil {
+			cancel(cause)
+		}
+		attempt := &upstreamAttempt{ctx: ctx, cancel: cancel, err: err}
+		if err == nil {
+			attempt.resp = &http.Response{StatusCode: status}
+		}
+		return attempt
+	}
+	refused := fmt.Errorf("dial tcp 127.0.0.1:1: %w", syscall.ECONNREFUSED)
+
+	tests := []struct {
+		name    string
+		attempt *upstreamAttempt
+		want    string
+	}{
+		{"configured status", attemptWith(503, nil, nil), "status_503"},
+		{"other 5xx", attemptWith(500, nil, nil), ""},
+		{"success", attemptWith(200, nil, nil), ""},
+		{"connection error", attemptWith(0, refused, nil), "connection"},
+		// timeout no está en retry_on_errors
+		{"service timeout", attemptWith(0, context.Canceled, context.DeadlineExceeded), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.retryReason(tt.attempt); got != tt.want {
+				t.Errorf("retryReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := newRetryPolicy(testRetryConfig())
+
+	tests := []struct {
+		retry int
+		limit time.Duration // el jitter elige entre 0 y este valor
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{10, 40 * time.Millisecond}, // acotado por max_backoff_ms
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("retry %d", tt.retry), func(t *testing.T) {
+			var largest time.Duration
+			for i := 0; i < 500; i++ {
+				delay := policy.backoff(tt.retry)
+				if delay < 0 || delay > tt.limit {
+					t.Fatalf("backoff = %v, want within [0, %v]", delay, tt.limit)
+				}
+				largest = max(largest, delay)
+			}
+			// Con 500 muestras el jitter debería acercarse al límite
+			if largest < tt.limit/2 {
+				t.Errorf("largest backoff = %v, want close to %v", largest, tt.limit)
+			}
+		})
+	}
+
+	if delay := newRetryPolicy(config.RetryConfig{}).backoff(1); delay != 0 {
+		t.Errorf("backoff without initial_backoff_ms = %v, want 0", delay)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		ratio        float64
+		minPerSecond int
+		deposits     int
+		want         int // reintentos permitidos
+	}{
+		{"empty budget", 0.5, 0, 0, 0},
+		{"half of the requests", 0.5, 0, 10, 5},
+		{"balance capped to the window", 0.5, 0, 1000, retryBudgetWindow / 2},
+		{"reserve without traffic", 0.5, 3, 0, 3},
+		{"reserve on top of the balance", 0.5, 2, 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := newRetryBudget(tt.ratio, tt.minPerSecond)
+			for i := 0; i < tt.deposits; i++ {
+				budget.deposit()
+			}
+
+			allowed := 0
+			for budget.withdraw() {
+				allowed++
+			}
+			if allowed != tt.want {
+				t.Errorf("%d retries allowed, want %d", allowed, tt.want)
+			}
+		})
+	}
+}
+
+func TestBufferRequestBody(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), maxRetryBodyBytes+1)
+
+	tests := []struct {
+		name       string
+		body       io.Reader
+		length     int64
+		replayable bool
+		want       string
+	}{
+		{"no body", nil, 0, true, ""},
+		{"small body", strings.NewReader(`{"id":1}`), 8, true, `{"id":1}`},
+		{"unknown length", strings.NewReader("chunked"), -1, false, ""},
+		{"over the limit", bytes.NewReader(large), int64(len(large)), false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/leads", tt.body)
+			req.ContentLength = tt.length
+
+			body, replayable, err := bufferRequestBody(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replayable != tt.replayable || string(body) != tt.want {
+				t.Errorf("bufferRequestBody = %q, %v; want %q, %v", body, replayable, tt.want, tt.replayable)
+			}
+		})
+	}
+}
+
+// Reintentos de punta a punta: el upstream falla las primeras veces y la
+// request se reenvía con el mismo body mientras el método y los intentos lo permitan
+func TestRetryLoop(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		failures int // respuestas 503 antes de responder 200
+		status   int
+		attempts string
+	}{
+		{"recovers on the second attempt", http.MethodPut, 1, http.StatusOK, "2"},
+		{"gives up after max_attempts", http.MethodGet, 5, http.StatusServiceUnavailable, "3"},
+		{"non-idempotent method is not retried", http.MethodPost, 1, http.StatusServiceUnavailable, "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var bodies []string
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mutex.Lock()
+				bodies = append(bodies, string(body))
+				failing := len(bodies) <= tt.failures
+				mutex.Unlock()
+
+				w.Header().Set("Content-Type", "application/json")
+				if failing {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+				w.Write([]byte(`{"ok":true}`))
+			}))
+			defer upstream.Close()
+
+			cfg := testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {"services": [{
+    "name": "leads", "prefix": "/leads", "base_url": %q, "timeout": 5,
+    "retry": {"max_attempts": 3, "methods": ["GET", "PUT"], "initial_backoff_ms": 1},
+    "envelope": {"mode": "wrap_preserve_status"}
+  }]}
+}`, upstream.URL))
+			e, _ := newTestGateway(t, cfg)
+
+			req := httptest.NewRequest(tt.method, "/leads/items", strings.NewReader(`{"id":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.status, rec.Body.String())
+			}
+			if got := rec.Header().Get("X-Upstream-Attempts"); got != tt.attempts {
+				t.Errorf("X-Upstream-Attempts = %s, want %s", got, tt.attempts)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if calls := fmt.Sprint(len(bodies)); calls != tt.attempts {
+				t.Errorf("upstream received %s requests, want %s", calls, tt.attempts)
+			}
+			for i, body := range bodies {
+				if body != `{"id":1}` {
+					t.Errorf("attempt %d sent body %q", i+1, body)
+				}
+			}
+		})
+	}
+}
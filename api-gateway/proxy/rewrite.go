@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"api-gateway/config"
+)
+
+// Reglas de reescritura compiladas de un servicio
+type pathRewriter struct {
+	prefix     string
+	keepPrefix bool
+	rules      []rewriteRule
+}
+
+type rewriteRule struct {
+	regex       *regexp.Regexp
+	prefix      string
+	replacement string
+}
+
+func newPathRewriter(service config.ServiceConfig) *pathRewriter {
+	rewriter := &pathRewriter{
+		prefix:     service.Prefix,
+		keepPrefix: service.Rewrite.KeepPrefix,
+	}
+	for _, rule := range service.Rewrite.Rules {
+		compiled := rewriteRule{prefix: rule.Prefix, replacement: rule.Replacement}
+		if rule.Regex != "" {
+			// La configuración ya validó la expresión
+			compiled.regex = regexp.MustCompile(rule.Regex)
+		}
+		rewriter.rules = append(rewriter.rules, compiled)
+	}
+	return rewriter
+}
+
+// Path que se envía al upstream y el índice de la regla aplicada (-1 si ninguna)
+func (r *pathRewriter) rewrite(requestPath string) (string, int) {
+	path := requestPath
+	if !r.keepPrefix {
+		path = strings.TrimPrefix(requestPath, r.prefix)
+	}
+
+	applied := -1
+	for i, rule := range r.rules {
+		if rewritten, ok := rule.apply(path); ok {
+			path, applied = rewritten, i
+			break
+		}
+	}
+
+	// Si el path queda vacío, significa que se accedió exactamente al prefix
+	if path == "" {
+		path = "/"
+	}
+
+	// Asegurar que el path empiece con /
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return path, applied
+}
+
+func (rule rewriteRule) apply(path string) (string, bool) {
+	if rule.regex != nil {
+		if !rule.regex.MatchString(path) {
+			return "", false
+		}
+		return rule.regex.ReplaceAllString(path, rule.replacement), true
+	}
+
+	if path != rule.prefix && !strings.HasPrefix(path, strings.TrimSuffix(rule.prefix, "/")+"/") {
+		return "", false
+	}
+	return rule.replacement + strings.TrimPrefix(path, strings.TrimSuffix(rule.prefix, "/")), true
+}
+
+// Resultado de resolver una request contra la configuración de rutas
+type RouteResolution struct {
+	Service      string `json:"service"`
+	Path         string `json:"path"`
+	UpstreamPath string `json:"upstream_path"`
+	UpstreamURL  string `json:"upstream_url"`
+	Rule         *int   `json:"rule"`  // índice de la regla de rewrite aplicada
+	Route        string `json:"route"` // ruta que atiende la request, "default" si ninguna
+}
+
+// Calcular la URL de upstream para una request sin enviarla.
+// Con load balancer se usa base_url; el backend real se elige por request.
+func (h *Handler) ResolveRoute(req *http.Request) (*RouteResolution, bool) {
+	target := req.URL
+	for _, service := range h.config.Gateway.Services {
+		if target.Path != service.Prefix && !strings.HasPrefix(target.Path, service.Prefix+"/") {
+			continue
+		}
+
+		baseURL, routeName := service.BaseURL, "default"
+		if route := h.matchRoute(service.Name, req); route != nil {
+			baseURL, routeName = route.config.BaseURL, route.config.Name
+		}
+
+		upstreamPath, rule := h.rewriters[service.Name].rewrite(target.Path)
+		resolution := &RouteResolution{
+			Service:      service.Name,
+			Path:         target.Path,
+			UpstreamPath: upstreamPath,
+			UpstreamURL:  upstreamURL(baseURL, upstreamPath, target.RawQuery),
+			Route:        routeName,
+		}
+		if rule >= 0 {
+			resolution.Rule = &rule
+		}
+		return resolution, true
+	}
+	return nil, false
+}
+
+func upstreamURL(baseURL, path, rawQuery string) string {
+	targetURL := baseURL + path
+	if rawQuery != "" {
+		targetURL += "?" + rawQuery
+	}
+	return targetURL
+}
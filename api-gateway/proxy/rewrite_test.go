@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-gateway/config"
+)
+
+func TestPathRewriterRewrite(t *testing.T) {
+	rules := []config.RewriteRuleConfig{
+		{Regex: `^/v1/clientes/(\d+)$`, Replacement: "/customers/$1"},
+		{Regex: `^/v1/(?P<resource>[a-z]+)/export$`, Replacement: "/exports/${resource}"},
+		{Prefix: "/v1", Replacement: "/api/v2"},
+		{Prefix: "/legacy/", Replacement: ""},
+	}
+
+	tests := []struct {
+		name       string
+		keepPrefix bool
+		rules      []config.RewriteRuleConfig
+		path       string
+		want       string
+		rule       int
+	}{
+		{"prefix stripped without rules", false, nil, "/leads/items", "/items", -1},
+		{"exact prefix becomes /", false, nil, "/leads", "/", -1},
+		{"keep_prefix", true, nil, "/leads/items", "/leads/items", -1},
+		{"regex with numbered group", false, rules, "/leads/v1/clientes/42", "/customers/42", 0},
+		{"regex with named group", false, rules, "/leads/v1/polizas/export", "/exports/polizas", 1},
+		{"first matching rule wins", false, rules, "/leads/v1/clientes/42/notas", "/api/v2/clientes/42/notas", 2},
+		{"prefix rule on the exact path", false, rules, "/leads/v1", "/api/v2", 2},
+		{"prefix rule needs a segment boundary", false, rules, "/leads/v10/items", "/v10/items", -1},
+		{"prefix replaced by nothing keeps the leading /", false, rules, "/leads/legacy/items", "/items", 3},
+		{"rules see the prefix with keep_prefix", true, []config.RewriteRuleConfig{{Prefix: "/leads/v1", Replacement: "/v2"}}, "/leads/v1/items", "/v2/items", 0},
+		{"regex result without a leading /", false, []config.RewriteRuleConfig{{Regex: `^/(.*)$`, Replacement: "$1"}}, "/leads/items", "/items", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewriter := newPathRewriter(config.ServiceConfig{
+				Prefix:  "/leads",
+				Rewrite: config.RewriteConfig{KeepPrefix: tt.keepPrefix, Rules: tt.rules},
+			})
+
+			path, rule := rewriter.rewrite(tt.path)
+			if path != tt.want || rule != tt.rule {
+				t.Errorf("rewrite(%s) = %s, rule %d; want %s, rule %d", tt.path, path, rule, tt.want, tt.rule)
+			}
+		})
+	}
+}
+
+func TestResolveRoute(t *testing.T) {
+	cfg := &config.Config{Gateway: config.GatewayConfig{Services: []config.ServiceConfig{
+		{
+			Name: "leads", Prefix: "/leads", BaseURL: "http://leads:3000",
+			Rewrite: config.RewriteConfig{Rules: []config.RewriteRuleConfig{{Prefix: "/v1", Replacement: "/api/v2"}}},
+		},
+		{Name: "pagos", Prefix: "/pagos", BaseURL: "http://pagos:3000"},
+	}}}
+	handler := &Handler{config: cfg, rewriters: make(map[string]*pathRewriter)}
+	for _, service := range cfg.Gateway.Services {
+		handler.rewriters[service.Name] = newPathRewriter(service)
+	}
+
+	tests := []struct {
+		target  string
+		service string
+		url     string
+		rule    int // -1 = ninguna
+	}{
+		{"/leads/v1/items?page=2", "leads", "http://leads:3000/api/v2/items?page=2", 0},
+		{"/leads/items", "leads", "http://leads:3000/items", -1},
+		{"/pagos", "pagos", "http://pagos:3000/", -1},
+		{"/leadsx/items", "", "", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			resolution, found := handler.ResolveRoute(httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if tt.service == "" {
+				if found {
+					t.Fatalf("resolved to %s, want no service", resolution.Service)
+				}
+				return
+			}
+			if !found {
+				t.Fatal("no service resolved")
+			}
+
+			rule := -1
+			if resolution.Rule != nil {
+				rule = *resolution.Rule
+			}
+			if resolution.Service != tt.service || resolution.UpstreamURL != tt.url || rule != tt.rule || resolution.Route != "default" {
+				t.Errorf("resolution = %+v (rule %d), want %s %s rule %d", resolution, rule, tt.service, tt.url, tt.rule)
+			}
+		})
+	}
+}
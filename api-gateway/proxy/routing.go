@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"api-gateway/config"
+)
+
+// Ruta compilada de un servicio
+type routeRule struct {
+	config  config.RouteConfig
+	hosts   []string
+	methods map[string]bool
+}
+
+// Orden de evaluación: priority descendente, después la ruta con más
+// condiciones (más específica) y, a igualdad, el orden de la configuración
+func newRouteRules(routes []config.RouteConfig) []*routeRule {
+	rules := make([]*routeRule, 0, len(routes))
+	for _, route := range routes {
+		rule := &routeRule{config: route, methods: make(map[string]bool)}
+		for _, host := range route.Match.Hosts {
+			rule.hosts = append(rule.hosts, strings.ToLower(host))
+		}
+		for _, method := range route.Match.Methods {
+			rule.methods[method] = true
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].config.Priority != rules[j].config.Priority {
+			return rules[i].config.Priority > rules[j].config.Priority
+		}
+		return rules[i].conditions() > rules[j].conditions()
+	})
+	return rules
+}
+
+func (r *routeRule) conditions() int {
+	count := len(r.config.Match.Headers) + len(r.config.Match.Query)
+	if len(r.hosts) > 0 {
+		count++
+	}
+	if len(r.methods) > 0 {
+		count++
+	}
+	return count
+}
+
+func (r *routeRule) matches(req *http.Request) bool {
+	if len(r.hosts) > 0 && !matchesHost(r.hosts, req.Host) {
+		return false
+	}
+	if len(r.methods) > 0 && !r.methods[req.Method] {
+		return false
+	}
+	for name, value := range r.config.Match.Headers {
+		actual, exists := req.Header[http.CanonicalHeaderKey(name)]
+		if !exists || (value != "" && actual[0] != value) {
+			return false
+		}
+	}
+	query := req.URL.Query()
+	for name, value := range r.config.Match.Query {
+		if !query.Has(name) || (value != "" && query.Get(name) != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesHost(hosts []string, requestHost string) bool {
+	host := strings.ToLower(requestHost)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, pattern := range hosts {
+		if pattern == host {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Primera ruta del servicio que acepta la request
+func (h *Handler) matchRoute(serviceName string, req *http.Request) *routeRule {
+	for _, rule := range h.routes[serviceName] {
+		if rule.matches(req) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Entrada de la tabla de ruteo compilada
+type RouteTableEntry struct {
+	Service  string            `json:"service"`
+	Prefix   string            `json:"prefix"`
+	Route    string            `json:"route"` // "default" para el backend del servicio
+	Priority int               `json:"priority"`
+	Hosts    []string          `json:"hosts,omitempty"`
+	Methods  []string          `json:"methods,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Query    map[string]string `json:"query,omitempty"`
+	Target   string            `json:"target"`
+}
+
+// Tabla de ruteo en el orden en que se evalúa: por servicio, sus rutas y al final el default
+func (h *Handler) RouteTable() []RouteTableEntry {
+	var table []RouteTableEntry
+	for _, service := range h.config.Gateway.Services {
+		for _, rule := range h.routes[service.Name] {
+			table = append(table, RouteTableEntry{
+				Service:  service.Name,
+				Prefix:   service.Prefix,
+				Route:    rule.config.Name,
+				Priority: rule.config.Priority,
+				Hosts:    rule.config.Match.Hosts,
+				Methods:  rule.config.Match.Methods,
+				Headers:  rule.config.Match.Headers,
+				Query:    rule.config.Match.Query,
+				Target:   rule.config.BaseURL,
+			})
+		}
+
+		target := service.BaseURL
+		if service.LoadBalancer.Enabled {
+			target = "load_balancer:" + service.LoadBalancer.Strategy
+		}
+		table = append(table, RouteTableEntry{
+			Service: service.Name,
+			Prefix:  service.Prefix,
+			Route:   "default",
+			Target:  target,
+		})
+	}
+	return table
+}
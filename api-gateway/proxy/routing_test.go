@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"api-gateway/config"
+)
+
+func TestNewRouteRulesOrder(t *testing.T) {
+	routes := []config.RouteConfig{
+		{Name: "any-host", Match: config.RouteMatch{Hosts: []string{"*.example.com"}}},
+		{Name: "beta-header", Match: config.RouteMatch{Headers: map[string]string{"X-Beta": "1"}}},
+		{Name: "beta-post", Match: config.RouteMatch{Methods: []string{"POST"}, Headers: map[string]string{"X-Beta": "1"}}},
+		{Name: "urgent", Priority: 10, Match: config.RouteMatch{Query: map[string]string{"urgent": ""}}},
+		{Name: "low", Priority: -1, Match: config.RouteMatch{Methods: []string{"GET"}, Query: map[string]string{"a": "", "b": ""}}},
+	}
+
+	var order []string
+	for _, rule := range newRouteRules(routes) {
+		order = append(order, rule.config.Name)
+	}
+
+	// priority primero, después más condiciones y, a igualdad, el orden de la config
+	want := []string{"urgent", "beta-post", "any-host", "beta-header", "low"}
+	if !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestMatchesHost(t *testing.T) {
+	hosts := []string{"api.example.com", "*.clientes.example.com"}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"api.example.com", true},
+		{"API.Example.com", true},
+		{"api.example.com:8443", true},
+		{"www.example.com", false},
+		{"acme.clientes.example.com", true},
+		{"a.b.clientes.example.com", true},
+		{"clientes.example.com", false},
+		{"otroclientes.example.com", false},
+		{"[::1]:8000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := matchesHost(hosts, tt.host); got != tt.want {
+				t.Errorf("matchesHost(%s) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+// Primera ruta que acepta la request, con las condiciones de cada ruta
+// combinadas con AND
+func TestMatchRoute(t *testing.T) {
+	handler := &Handler{routes: map[string][]*routeRule{"leads": newRouteRules([]config.RouteConfig{
+		{Name: "tenant-host", Match: config.RouteMatch{Hosts: []string{"*.tenants.example.com"}, Methods: []string{"GET", "HEAD"}}},
+		{Name: "beta-value", Match: config.RouteMatch{Headers: map[string]string{"X-Beta": "on"}}},
+		{Name: "debug-present", Match: config.RouteMatch{Headers: map[string]string{"X-Debug": ""}}},
+		{Name: "export-query", Match: config.RouteMatch{Query: map[string]string{"format": "csv", "all": ""}}},
+		{Name: "admin", Priority: 5, Match: config.RouteMatch{Headers: map[string]string{"X-User-Role": "admin"}}},
+	})}}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		host    string
+		headers map[string]string
+		want    string // "" = backend del servicio
+	}{
+		{"no conditions met", http.MethodGet, "/leads", "", nil, ""},
+		{"wildcard host and method", http.MethodGet, "/leads", "acme.tenants.example.com", nil, "tenant-host"},
+		{"wildcard host with another method", http.MethodPost, "/leads", "acme.tenants.example.com", nil, ""},
+		{"header with the expected value", http.MethodPost, "/leads", "", map[string]string{"x-beta": "on"}, "beta-value"},
+		{"header with another value", http.MethodPost, "/leads", "", map[string]string{"X-Beta": "off"}, ""},
+		{"header presence", http.MethodGet, "/leads", "", map[string]string{"X-Debug": ""}, "debug-present"},
+		{"all query conditions", http.MethodGet, "/leads?format=csv&all", "", nil, "export-query"},
+		{"one query condition missing", http.MethodGet, "/leads?format=csv", "", nil, ""},
+		{"priority over an earlier route", http.MethodGet, "/leads", "acme.tenants.example.com", map[string]string{"X-User-Role": "admin"}, "admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			got := ""
+			if route := handler.matchRoute("leads", req); route != nil {
+				got = route.config.Name
+			}
+			if got != tt.want {
+				t.Errorf("route = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
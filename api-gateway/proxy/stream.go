@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"api-gateway/config"
+
+	"github.com/labstack/echo/v4"
+)
+
+const streamBufferSize = 32 * 1024
+
+// Buffers reutilizables para copiar bodies sin reservar memoria por request
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, streamBufferSize)
+		return &buf
+	},
+}
+
+// Headers del upstream que se copian al hacer streaming de la respuesta
+var streamedResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Range",
+	"Accept-Ranges",
+	"Cache-Control",
+	"ETag",
+	"Last-Modified",
+	"Expires",
+}
+
+// Decidir si la respuesta se envía tal cual, sin leerla completa en memoria
+func (h *Handler) shouldStream(service config.ServiceConfig, c echo.Context, resp *http.Response) bool {
+	// Rutas o requests en modo passthrough, sin importar el status
+	if mode, _ := c.Get("envelope_mode").(string); mode == "passthrough" || isPassthroughPath(service, c.Request().URL.Path) {
+		return true
+	}
+
+	// Los errores se siguen envolviendo en el formato estándar
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false
+	}
+
+	// Contenido comprimido o binario no puede envolverse en JSON
+	if resp.Header.Get("Content-Encoding") != "" {
+		return true
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return false
+	}
+	return !isJSONContentType(contentType)
+}
+
+// Content types que siempre se reenvían a medida que llegan
+var streamingContentTypes = []string{
+	"text/event-stream",
+	"application/x-ndjson",
+}
+
+// Streams de larga duración: se envía cada chunk al cliente en cuanto llega
+// y no se les aplica el timeout del servicio
+func isLongLivedStream(service config.ServiceConfig, c echo.Context, resp *http.Response) bool {
+	if matchesServicePath(service, c.Request().URL.Path, service.Streaming.Paths) {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, contentType := range streamingContentTypes {
+		if mediaType == contentType {
+			return true
+		}
+	}
+	for _, contentType := range service.Streaming.ContentTypes {
+		if configured, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == configured {
+			return true
+		}
+	}
+	return false
+}
+
+func isPassthroughPath(service config.ServiceConfig, requestPath string) bool {
+	return matchesServicePath(service, requestPath, service.PassthroughPaths)
+}
+
+// Verificar si el path (sin el prefix del servicio) está bajo alguno de los paths dados
+func matchesServicePath(service config.ServiceConfig, requestPath string, paths []string) bool {
+	path := strings.TrimPrefix(requestPath, service.Prefix)
+	for _, prefix := range paths {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Copiar la respuesta del upstream al cliente con un buffer del pool.
+// Con flush, cada chunk se envía al cliente apenas se recibe.
+func (h *Handler) streamResponse(c echo.Context, resp *http.Response, flush bool) error {
+	h.addGatewayHeaders(c)
+
+	header := c.Response().Header()
+	header.Del("Content-Type")
+	for _, name := range streamedResponseHeaders {
+		if values := resp.Header.Values(name); len(values) > 0 {
+			header[name] = values
+		}
+	}
+
+	if flush {
+		// Evitar que proxies intermedios (nginx) acumulen el stream
+		header.Del("Content-Length")
+		header.Set("X-Accel-Buffering", "no")
+	}
+
+	c.Response().WriteHeader(resp.StatusCode)
+
+	bufPtr := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(bufPtr)
+
+	if !flush {
+		_, err := io.CopyBuffer(c.Response(), resp.Body, *bufPtr)
+		return err
+	}
+
+	controller := http.NewResponseController(c.Response().Writer)
+	if err := controller.Flush(); err != nil {
+		return err
+	}
+
+	buf := *bufPtr
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, writeErr := c.Response().Write(buf[:n]); writeErr != nil {
+				return writeErr
+			}
+			if flushErr := controller.Flush(); flushErr != nil {
+				return flushErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
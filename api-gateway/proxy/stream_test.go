@@ -0,0 +1,209 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/health"
+	"api-gateway/storage"
+	"api-gateway/testutil"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Tamaño del archivo que se descarga y sube en el benchmark
+const streamBenchmarkPayload = 32 * 1024 * 1024
+
+// Gateway con un servicio /polizas hacia el upstream
+func newStreamTestGateway(t testing.TB, upstreamURL string) *echo.Echo {
+	t.Helper()
+
+	service := config.ServiceConfig{
+		Name:    "poliza",
+		BaseURL: upstreamURL,
+		Prefix:  "/polizas",
+		Timeout: 30,
+	}
+	cfg := &config.Config{Gateway: config.GatewayConfig{Services: []config.ServiceConfig{service}}}
+	handler, err := NewHandler(cfg, health.NewChecker(), storage.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.Any("/polizas/*", handler.HandleProxy(service))
+	return e
+}
+
+// ResponseWriter que descarta el body para no medir la memoria del cliente
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(statusCode int)  {}
+
+// Memoria reservada por request al descargar y subir archivos grandes a
+// través del proxy; debe quedar muy por debajo del tamaño del payload.
+//
+// Uso: go test ./proxy -run '^$' -bench BenchmarkStreamRelay
+func BenchmarkStreamRelay(b *testing.B) {
+	payload := bytes.Repeat([]byte("x"), streamBenchmarkPayload)
+
+	// Upstream de prueba: sirve un PDF y descarta los uploads
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"stored":true}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(payload)
+	}))
+	defer upstream.Close()
+
+	e := newStreamTestGateway(b, upstream.URL)
+	testutil.SilenceStdout(b)
+
+	b.Run("download", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(payload)))
+		for i := 0; i < b.N; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/polizas/documento.pdf", nil)
+			e.ServeHTTP(&discardWriter{header: make(http.Header)}, req)
+		}
+	})
+
+	b.Run("upload", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(payload)))
+		for i := 0; i < b.N; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/polizas/documentos", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/pdf")
+			e.ServeHTTP(&discardWriter{header: make(http.Header)}, req)
+		}
+	})
+}
+
+// ResponseWriter que registra cada write y cada flush; received se cierra
+// con el primer write
+type chunkRecorder struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	writes   int
+	flushes  int
+	largest  int
+	received chan struct{}
+	once     sync.Once
+}
+
+func newChunkRecorder() *chunkRecorder {
+	return &chunkRecorder{header: make(http.Header), received: make(chan struct{})}
+}
+
+func (r *chunkRecorder) Header() http.Header        { return r.header }
+func (r *chunkRecorder) WriteHeader(statusCode int) { r.status = statusCode }
+func (r *chunkRecorder) Flush()                     { r.flushes++ }
+
+func (r *chunkRecorder) Write(b []byte) (int, error) {
+	r.writes++
+	r.largest = max(r.largest, len(b))
+	r.once.Do(func() { close(r.received) })
+	return r.body.Write(b)
+}
+
+// Un body más grande que el buffer del pool llega completo y en chunks: el
+// upstream no termina de enviarlo hasta que el cliente recibió el primero,
+// así que un proxy que lo leyera entero antes de responder no pasa el test.
+func TestStreamRelayLargeBody(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	payload := make([]byte, 4*streamBufferSize+123)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		flushed     bool // flush después de cada chunk
+	}{
+		{"binary download", "application/pdf", false},
+		{"event stream", "text/event-stream", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newChunkRecorder()
+			var buffered atomic.Bool
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write(payload[:streamBufferSize])
+				w.(http.Flusher).Flush()
+				select {
+				case <-rec.received:
+				case <-time.After(2 * time.Second):
+					buffered.Store(true)
+				}
+				w.Write(payload[streamBufferSize:])
+			}))
+			defer upstream.Close()
+
+			e := newStreamTestGateway(t, upstream.URL)
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/polizas/documento", nil))
+
+			if rec.status != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.status)
+			}
+			if buffered.Load() {
+				t.Error("the first chunk did not reach the client before the upstream finished")
+			}
+			if !bytes.Equal(rec.body.Bytes(), payload) {
+				t.Fatalf("received %d bytes that do not match the %d sent", rec.body.Len(), len(payload))
+			}
+			if rec.writes < 2 || rec.largest > streamBufferSize {
+				t.Errorf("%d writes of up to %d bytes, want several of at most %d", rec.writes, rec.largest, streamBufferSize)
+			}
+			if tt.flushed && rec.flushes < rec.writes {
+				t.Errorf("%d flushes for %d writes, want one per chunk", rec.flushes, rec.writes)
+			}
+		})
+	}
+}
+
+// Un upload más grande que el buffer del pool llega intacto al upstream
+func TestStreamRelayLargeUpload(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 4*streamBufferSize/16+7)
+	var received []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"stored":true}`))
+	}))
+	defer upstream.Close()
+
+	e := newStreamTestGateway(t, upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/polizas/documentos", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/pdf")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body.String())
+	}
+	if !bytes.Equal(received, payload) {
+		t.Errorf("upstream received %d bytes that do not match the %d sent", len(received), len(payload))
+	}
+}
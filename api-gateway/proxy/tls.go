@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/config"
+)
+
+// Cada cuánto se revisa si cambiaron los certificados en disco
+const tlsReloadCheckInterval = 10 * time.Second
+
+// Transport de un servicio con su configuración TLS. Cuando cambian los archivos
+// de CA o certificado se construye un transport nuevo; las requests en curso
+// terminan con el anterior.
+type upstreamTransport struct {
+	service   string
+	cfg       config.UpstreamTLSConfig
+	current   atomic.Pointer[http.Transport]
+	mutex     sync.Mutex
+	modTime   time.Time // última modificación de los archivos cargados
+	checkedAt time.Time
+}
+
+func newUpstreamTransport(service string, cfg config.UpstreamTLSConfig) (*upstreamTransport, error) {
+	t := &upstreamTransport{service: service, cfg: cfg}
+
+	modTime, err := t.filesModTime()
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", service, err)
+	}
+	transport, err := t.build()
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", service, err)
+	}
+	t.current.Store(transport)
+	t.modTime = modTime
+	t.checkedAt = time.Now()
+
+	if cfg.InsecureSkipVerify {
+		fmt.Printf("⚠️  TLS verification disabled for service %s (insecure_skip_verify), use only in development\n", service)
+	}
+	return t, nil
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.reloadIfChanged()
+	return t.current.Load().RoundTrip(req)
+}
+
+// Copia de la configuración TLS vigente, para las conexiones que no pasan por
+// el transport (WebSocket). Puede incluir h2 en NextProtos.
+func (t *upstreamTransport) TLSConfig() *tls.Config {
+	return t.current.Load().TLSClientConfig.Clone()
+}
+
+func (t *upstreamTransport) build() (*http.Transport, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         t.cfg.ServerName,
+		MinVersion:         config.TLSVersion(t.cfg.MinVersion),
+		InsecureSkipVerify: t.cfg.InsecureSkipVerify,
+	}
+
+	if t.cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(t.cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", t.cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.cfg.CertFile, t.cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	}, nil
+}
+
+// Mayor fecha de modificación entre los archivos configurados
+func (t *upstreamTransport) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{t.cfg.CAFile, t.cfg.CertFile, t.cfg.KeyFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Reconstruir el transport si algún archivo cambió; si la carga falla
+// (por ejemplo, un archivo a medio escribir) se sigue usando el anterior
+func (t *upstreamTransport) reloadIfChanged() {
+	if t.cfg.CAFile == "" && t.cfg.CertFile == "" {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if time.Since(t.checkedAt) < tlsReloadCheckInterval {
+		return
+	}
+	t.checkedAt = time.Now()
+
+	modTime, err := t.filesModTime()
+	if err != nil {
+		fmt.Printf("⚠️  TLS reload error [%s]: %v\n", t.service, err)
+		return
+	}
+	if !modTime.After(t.modTime) {
+		return
+	}
+
+	transport, err := t.build()
+	if err != nil {
+		fmt.Printf("⚠️  TLS reload error [%s], keeping previous certificates: %v\n", t.service, err)
+		return
+	}
+	t.modTime = modTime
+	previous := t.current.Swap(transport)
+	previous.CloseIdleConnections()
+
+	fmt.Printf("🔐 TLS certificates reloaded for service: %s\n", t.service)
+}
+
+// Transport del servicio, para que el health checker use la misma configuración TLS
+func (h *Handler) Transport(serviceName string) http.RoundTripper {
+	if transport, ok := h.transports[serviceName]; ok {
+		return transport
+	}
+	return nil
+}
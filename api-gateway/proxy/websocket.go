@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/metrics"
+	"api-gateway/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Verificar si la request es un handshake de WebSocket
+func isWebSocketRequest(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range strings.Split(r.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(value), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// Proxy de WebSocket: reenvía el handshake al upstream y, si acepta, conecta
+// ambos sockets y copia los frames en las dos direcciones.
+// Auth y rate limiting ya se aplicaron sobre el handshake en los middlewares del grupo.
+func (h *Handler) handleWebSocket(c echo.Context, service config.ServiceConfig) error {
+	targetURL, err := h.getTargetURL(service, c)
+	if err != nil {
+		return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerUpstream, middleware.CodeNoHealthyBackend, "Error determining target URL").WithCause(err))
+	}
+
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerGateway, middleware.CodeInternal, "Error determining target URL").WithCause(err))
+	}
+
+	upstreamConn, err := dialUpstream(target, time.Duration(service.Timeout)*time.Second, h.transports[service.Name].TLSConfig())
+	if err != nil {
+		metrics.UpstreamErrors.WithLabelValues(service.Name, upstreamErrorType(c.Request().Context(), err)).Inc()
+		return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerUpstream, middleware.CodeUpstreamUnavailable, "Service unavailable").WithCause(err))
+	}
+
+	// Enviar el handshake al upstream
+	handshake := h.createWebSocketHandshake(c, target)
+	upstreamConn.SetDeadline(time.Now().Add(time.Duration(service.Timeout) * time.Second))
+	if err := handshake.Write(upstreamConn); err != nil {
+		upstreamConn.Close()
+		metrics.UpstreamErrors.WithLabelValues(service.Name, upstreamErrorType(c.Request().Context(), err)).Inc()
+		return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerUpstream, middleware.CodeUpstreamUnavailable, "Service unavailable").WithCause(err))
+	}
+
+	upstreamReader := bufio.NewReader(upstreamConn)
+	resp, err := http.ReadResponse(upstreamReader, handshake)
+	if err != nil {
+		upstreamConn.Close()
+		metrics.UpstreamErrors.WithLabelValues(service.Name, upstreamErrorType(c.Request().Context(), err)).Inc()
+		return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusBadGateway, middleware.LayerUpstream, middleware.CodeUpstreamBadResponse, "Error reading WebSocket handshake").WithCause(err))
+	}
+	upstreamConn.SetDeadline(time.Time{})
+
+	// El upstream rechazó el upgrade: devolver su respuesta como cualquier otra
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer upstreamConn.Close()
+		defer resp.Body.Close()
+		return h.transformResponse(c, resp)
+	}
+
+	clientConn, clientBuf, err := c.Response().Hijack()
+	if err != nil {
+		upstreamConn.Close()
+		return h.sendErrorResponse(c, middleware.NewGatewayError(http.StatusInternalServerError, middleware.LayerGateway, middleware.CodeInternal, "WebSocket upgrade not supported").WithCause(err))
+	}
+
+	// Completar el handshake con el cliente
+	if err := resp.Write(clientConn); err != nil {
+		clientConn.Close()
+		upstreamConn.Close()
+		return nil
+	}
+
+	fmt.Printf("[WEBSOCKET] %s connected: %s -> %s\n", service.Name, c.Request().URL.Path, target.String())
+
+	metrics.WebSocketConnections.WithLabelValues(service.Name).Inc()
+	defer metrics.WebSocketConnections.WithLabelValues(service.Name).Dec()
+
+	reason := relayWebSocket(clientConn, clientBuf.Reader, upstreamConn, upstreamReader,
+		time.Duration(service.WebSocket.IdleTimeoutSeconds)*time.Second,
+		time.Duration(service.WebSocket.MaxLifetimeSeconds)*time.Second,
+	)
+
+	fmt.Printf("[WEBSOCKET] %s closed: %s (%s)\n", service.Name, c.Request().URL.Path, reason)
+	return nil
+}
+
+func dialUpstream(target *url.URL, timeout time.Duration, tlsConfig *tls.Config) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	host := target.Host
+	switch target.Scheme {
+	case "https", "wss":
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "443")
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = target.Hostname()
+		}
+		// El upgrade es de HTTP/1.1; el transport agrega h2 a NextProtos
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		return tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	default:
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "80")
+		}
+		return dialer.Dial("tcp", host)
+	}
+}
+
+func (h *Handler) createWebSocketHandshake(c echo.Context, target *url.URL) *http.Request {
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        target,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       target.Host,
+	}
+
+	h.copyRequestHeaders(c.Request().Header, req.Header)
+	h.addProxyHeaders(req, c)
+
+	// Headers hop-by-hop necesarios para el upgrade
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	return req
+}
+
+// Copiar datos en ambas direcciones hasta que un lado cierre o se cumpla
+// un timeout. Devuelve el motivo del cierre.
+func relayWebSocket(clientConn net.Conn, clientReader io.Reader, upstreamConn net.Conn, upstreamReader io.Reader, idleTimeout, maxLifetime time.Duration) string {
+	var lastActivity int64
+	touch := func() { atomic.StoreInt64(&lastActivity, time.Now().UnixNano()) }
+	touch()
+
+	done := make(chan string, 2)
+	var once sync.Once
+	closeAll := func() {
+		once.Do(func() {
+			clientConn.Close()
+			upstreamConn.Close()
+		})
+	}
+
+	copyFrames := func(dst net.Conn, src io.Reader, direction string) {
+		bufPtr := bufferPool.Get().(*[]byte)
+		defer bufferPool.Put(bufPtr)
+		buf := *bufPtr
+
+		for {
+			n, err := src.Read(buf)
+			if n > 0 {
+				touch()
+				if _, writeErr := dst.Write(buf[:n]); writeErr != nil {
+					done <- direction + " write closed"
+					return
+				}
+			}
+			if err != nil {
+				done <- direction + " closed"
+				return
+			}
+		}
+	}
+
+	go copyFrames(upstreamConn, clientReader, "client")
+	go copyFrames(clientConn, upstreamReader, "upstream")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lifetime <-chan time.Time
+	if maxLifetime > 0 {
+		timer := time.NewTimer(maxLifetime)
+		defer timer.Stop()
+		lifetime = timer.C
+	}
+
+	reason := ""
+	for reason == "" {
+		select {
+		case reason = <-done:
+		case <-lifetime:
+			reason = "max lifetime reached"
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(&lastActivity)))
+			if idleTimeout > 0 && idle > idleTimeout {
+				reason = "idle timeout"
+			}
+		}
+	}
+
+	closeAll()
+	return reason
+}
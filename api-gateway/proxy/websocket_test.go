@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/testutil"
+)
+
+// Upstream TLS con HTTP/2 habilitado que acepta el upgrade a WebSocket por
+// HTTP/1.1 y devuelve lo que recibe
+func newWebSocketEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketRequest(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"proto":"` + r.Proto + `"}`))
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+		io.Copy(conn, buf)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Un wss hacia un upstream que negocia h2 por ALPN tiene que usar HTTP/1.1,
+// aunque el transport del servicio ya haya hecho requests por HTTP/2
+func TestWebSocketOverTLSWithHTTP2Upstream(t *testing.T) {
+	testutil.SilenceStdout(t)
+
+	upstream := newWebSocketEchoServer(t)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testutil.LoadConfig(t, fmt.Sprintf(`{
+  "gateway": {"services": [{
+    "name": "chat", "prefix": "/chat", "base_url": %q, "timeout": 5,
+    "tls": {"ca_file": %q}
+  }]}
+}`, upstream.URL, caFile))
+	e, _ := newTestGateway(t, cfg)
+	gateway := httptest.NewServer(e)
+	defer gateway.Close()
+
+	// Una request normal primero: el transport del servicio negocia h2
+	resp, err := http.Get(gateway.URL + "/chat/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "HTTP/2.0") {
+		t.Fatalf("upstream request did not use HTTP/2: %s", body)
+	}
+
+	conn, err := net.DialTimeout("tcp", gateway.Listener.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /chat/socket HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", gateway.Listener.Addr())
+
+	reader := bufio.NewReader(conn)
+	handshake, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("reading handshake: %v", err)
+	}
+	if handshake.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(handshake.Body)
+		t.Fatalf("handshake status = %d, want 101 (%s)", handshake.StatusCode, body)
+	}
+
+	// El relay copia bytes sin interpretar los frames
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(reader, echo); err != nil || string(echo) != "ping" {
+		t.Fatalf("echo = %q, %v; want ping", echo, err)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/health"
+	"api-gateway/middleware"
+	"api-gateway/proxy"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/labstack/echo/v4"
+)
+
+// gatewayRuntime agrupa todo lo que se construye a partir de config.json:
+// rutas, proxy handler (load balancers, circuit breakers, cache) y health checks
+type gatewayRuntime struct {
+	config        *config.Config
+	router        *echo.Echo
+	proxyHandler  *proxy.Handler
+	healthChecker *health.Checker
+	version       uint64
+	checksum      string
+	loadedAt      time.Time
+	cancel        context.CancelFunc
+}
+
+// Resultado de la última recarga
+type reloadStatus struct {
+	Success   bool      `json:"success"`
+	Version   uint64    `json:"version"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// previous es el runtime que se reemplaza en una recarga (nil al iniciar);
+// sus circuit breakers y pesos de grupos pasan al nuevo runtime
+func (gw *APIGateway) buildRuntime(cfg *config.Config, previous *gatewayRuntime) (*gatewayRuntime, error) {
+	checksum, err := configChecksum(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error computing config checksum: %w", err)
+	}
+
+	router := echo.New()
+	router.HideBanner = true
+	router.HidePort = true
+
+	healthChecker := health.NewChecker()
+	proxyHandler, err := proxy.NewHandler(cfg, healthChecker, gw.store)
+	if err != nil {
+		return nil, fmt.Errorf("error building proxy handler: %w", err)
+	}
+	router.HTTPErrorHandler = proxyHandler.HandleError
+	if previous != nil {
+		proxyHandler.InheritState(previous.proxyHandler)
+	}
+
+	for _, service := range cfg.Gateway.Services {
+		setupServiceRoutes(router, proxyHandler, service)
+
+		// Agregar al health checker si está habilitado
+		if service.HealthCheck.Enabled {
+			healthURL := service.BaseURL + service.HealthCheck.Endpoint
+			interval := time.Duration(service.HealthCheck.IntervalSeconds) * time.Second
+			healthChecker.AddService(service.Name, healthURL, interval, proxyHandler.Transport(service.Name))
+		}
+	}
+
+	gw.version++
+
+	return &gatewayRuntime{
+		config:        cfg,
+		router:        router,
+		proxyHandler:  proxyHandler,
+		healthChecker: healthChecker,
+		version:       gw.version,
+		checksum:      checksum,
+		loadedAt:      time.Now(),
+	}, nil
+}
+
+func (rt *gatewayRuntime) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	rt.cancel = cancel
+	rt.healthChecker.Start(ctx)
+	rt.proxyHandler.Auth().Start(ctx)
+}
+
+func (rt *gatewayRuntime) stop() {
+	if rt.cancel != nil {
+		rt.cancel()
+	}
+}
+
+// Reload vuelve a leer config.json y reemplaza el runtime activo.
+// Si la nueva configuración no es válida se mantiene la anterior.
+func (gw *APIGateway) Reload() error {
+	gw.reloadMutex.Lock()
+	defer gw.reloadMutex.Unlock()
+
+	previous := gw.current()
+
+	cfg, err := config.LoadConfig(gw.configPath)
+	if err != nil {
+		return gw.reloadFailed(previous, fmt.Errorf("error loading config: %w", err))
+	}
+
+	checksum, err := configChecksum(cfg)
+	if err != nil {
+		return gw.reloadFailed(previous, err)
+	}
+	if checksum == previous.checksum {
+		return nil
+	}
+
+	if cfg.Gateway.Port != previous.config.Gateway.Port {
+		fmt.Printf("⚠️  Port change (%s -> %s) requires a restart, keeping current listener\n",
+			previous.config.Gateway.Port, cfg.Gateway.Port)
+	}
+	if !reflect.DeepEqual(cfg.Gateway.TLS, previous.config.Gateway.TLS) {
+		fmt.Println("⚠️  Listener TLS change requires a restart, keeping current listener")
+	}
+
+	if !reflect.DeepEqual(cfg.Storage, previous.config.Storage) {
+		fmt.Println("⚠️  Storage change requires a restart, keeping current store")
+	}
+
+	rt, err := gw.buildRuntime(cfg, previous)
+	if err != nil {
+		return gw.reloadFailed(previous, err)
+	}
+
+	// Las requests en curso terminan con el runtime anterior
+	rt.start()
+	gw.runtime.Store(rt)
+	previous.stop()
+
+	gw.lastReload = reloadStatus{Success: true, Version: rt.version, Timestamp: rt.loadedAt}
+	fmt.Printf("✅ Configuration reloaded: version %d (%s), %d services\n",
+		rt.version, rt.checksum, len(cfg.Gateway.Services))
+	return nil
+}
+
+func (gw *APIGateway) reloadFailed(previous *gatewayRuntime, err error) error {
+	gw.lastReload = reloadStatus{
+		Success:   false,
+		Version:   previous.version,
+		Error:     err.Error(),
+		Timestamp: time.Now(),
+	}
+	fmt.Printf("❌ Configuration reload failed, keeping version %d: %v\n", previous.version, err)
+	return err
+}
+
+// Observar el directorio del archivo de configuración; muchos editores y los
+// ConfigMaps de Kubernetes reemplazan el archivo en lugar de escribirlo
+func (gw *APIGateway) watchConfig(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	configFile, err := filepath.Abs(gw.configPath)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		// Agrupar ráfagas de eventos en una sola recarga
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == configFile || filepath.Base(event.Name) == "..data" {
+					debounce = time.After(500 * time.Millisecond)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Printf("⚠️  Config watcher error: %v\n", err)
+			case <-debounce:
+				debounce = nil
+				fmt.Println("🔄 Config file changed, reloading configuration...")
+				gw.Reload()
+			}
+		}
+	}()
+
+	fmt.Printf("👀 Watching %s for changes\n", configFile)
+	return nil
+}
+
+// Las rutas de administración usan la autenticación de la configuración activa.
+// Sin auth.enabled no hay forma de verificar el rol admin, así que se rechazan.
+func (gw *APIGateway) adminMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rt := gw.current()
+			if !rt.config.Auth.Enabled {
+				return gatewayError(http.StatusForbidden, middleware.CodeForbidden, "admin API requires auth.enabled")
+			}
+			auth := rt.proxyHandler.Auth()
+			return auth.JWTMiddleware()(auth.RequireRole("admin")(next))(c)
+		}
+	}
+}
+
+func (gw *APIGateway) getConfigVersion(c echo.Context) error {
+	rt := gw.current()
+
+	gw.reloadMutex.Lock()
+	lastReload := gw.lastReload
+	gw.reloadMutex.Unlock()
+
+	services := make([]string, 0, len(rt.config.Gateway.Services))
+	for _, service := range rt.config.Gateway.Services {
+		services = append(services, service.Name)
+	}
+
+	response := GatewayResponse{
+		Data: map[string]interface{}{
+			"version":     rt.version,
+			"checksum":    rt.checksum,
+			"loaded_at":   rt.loadedAt.Format(time.RFC3339),
+			"config_path": gw.configPath,
+			"services":    services,
+			"last_reload": lastReload,
+		},
+		Success:      true,
+		ErrorMessage: nil,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (gw *APIGateway) reloadConfig(c echo.Context) error {
+	if err := gw.Reload(); err != nil {
+		errorMsg := err.Error()
+		return c.JSON(http.StatusOK, GatewayResponse{
+			Data:         map[string]interface{}{"version": gw.current().version},
+			Success:      false,
+			ErrorMessage: &errorMsg,
+		})
+	}
+
+	rt := gw.current()
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data: map[string]interface{}{
+			"version":  rt.version,
+			"checksum": rt.checksum,
+		},
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+// Checksum de la configuración efectiva (después de aplicar defaults)
+func configChecksum(cfg *config.Config) (string, error) {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])[:12], nil
+}
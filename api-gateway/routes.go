@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"api-gateway/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GET /admin/routes: tabla de ruteo compilada, en orden de evaluación
+func (gw *APIGateway) getRoutes(c echo.Context) error {
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data: map[string]interface{}{
+			"routes": gw.current().proxyHandler.RouteTable(),
+		},
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+// GET /admin/routes/test?path=/leads/123&method=POST&host=api.example.com&header=X-Api-Version:2
+// Muestra la URL de upstream que resultaría para una request, sin enviarla
+func (gw *APIGateway) testRoute(c echo.Context) error {
+	path := c.QueryParam("path")
+	if path == "" {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, "query parameter path is required, e.g. ?path=/leads/123")
+	}
+
+	method := c.QueryParam("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), path, nil)
+	if err != nil || !strings.HasPrefix(req.URL.Path, "/") {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, fmt.Sprintf("invalid path %q", path))
+	}
+	req.Host = c.QueryParam("host")
+	for _, header := range c.QueryParams()["header"] {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, fmt.Sprintf("invalid header %q, expected Name:value", header))
+		}
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	resolution, ok := gw.current().proxyHandler.ResolveRoute(req)
+	if !ok {
+		return gatewayError(http.StatusNotFound, middleware.CodeNotFound, fmt.Sprintf("no service matches %s", req.URL.Path))
+	}
+
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data:         resolution,
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Store en memoria, local a cada réplica del gateway
+type MemoryStore struct {
+	entries  map[string]memoryEntry
+	limiters map[string]*memoryLimiter
+	mutex    sync.RWMutex
+	stop     chan struct{}
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+type memoryLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:  make(map[string]memoryEntry),
+		limiters: make(map[string]*memoryLimiter),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (ms *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	entry, exists := ms.entries[key]
+	if !exists || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (ms *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	ms.entries[key] = entry
+	return nil
+}
+
+func (ms *MemoryStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if existing, exists := ms.entries[key]; exists && (existing.expiresAt.IsZero() || time.Now().Before(existing.expiresAt)) {
+		return false, nil
+	}
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	ms.entries[key] = entry
+	return true, nil
+}
+
+func (ms *MemoryStore) Delete(ctx context.Context, key string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	delete(ms.entries, key)
+	return nil
+}
+
+func (ms *MemoryStore) Allow(ctx context.Context, key string, limit float64, burst int) (RateLimitResult, error) {
+	ms.mutex.Lock()
+	entry, exists := ms.limiters[key]
+	if !exists {
+		entry = &memoryLimiter{limiter: rate.NewLimiter(rate.Limit(limit), burst)}
+		ms.limiters[key] = entry
+	} else if entry.limiter.Limit() != rate.Limit(limit) || entry.limiter.Burst() != burst {
+		// La configuración cambió después de una recarga
+		now := time.Now()
+		entry.limiter.SetLimitAt(now, rate.Limit(limit))
+		entry.limiter.SetBurstAt(now, burst)
+	}
+	entry.lastSeen = time.Now()
+	ms.mutex.Unlock()
+
+	reservation := entry.limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		// Cancelar la reserva ya que vamos a rechazar
+		reservation.Cancel()
+		return RateLimitResult{Allowed: false, RetryAfter: delay}, nil
+	}
+
+	remaining := int(math.Floor(entry.limiter.Tokens()))
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RateLimitResult{Allowed: true, Remaining: remaining}, nil
+}
+
+func (ms *MemoryStore) Close() error {
+	select {
+	case <-ms.stop:
+	default:
+		close(ms.stop)
+	}
+	return nil
+}
+
+// Limpiar entradas expiradas y limiters inactivos periódicamente
+func (ms *MemoryStore) StartCleanup() {
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ms.stop:
+				return
+			case <-ticker.C:
+				ms.cleanup()
+			}
+		}
+	}()
+}
+
+func (ms *MemoryStore) cleanup() {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	now := time.Now()
+	for key, entry := range ms.entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(ms.entries, key)
+		}
+	}
+
+	// Un limiter sin uso en los últimos 10 minutos ya recuperó todo su burst
+	for key, entry := range ms.limiters {
+		if now.Sub(entry.lastSeen) > 10*time.Minute {
+			delete(ms.limiters, key)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"api-gateway/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Token bucket atómico en Redis, compartido por todas las réplicas del gateway
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call("HMGET", key, "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(burst, tokens + elapsed * rate / 1000)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call("HSET", key, "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", key, math.ceil(burst * 1000 / rate) + 1000)
+
+return {allowed, math.floor(tokens), retry}
+`)
+
+// Store respaldado por Redis
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisStore(cfg config.RedisConfig) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Address,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("error connecting to redis at %s: %w", cfg.Address, err)
+	}
+
+	fmt.Printf("🗄️  Redis storage connected: %s (db %d)\n", cfg.Address, cfg.DB)
+
+	return &RedisStore{
+		client: client,
+		prefix: cfg.KeyPrefix,
+	}, nil
+}
+
+func (rs *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := rs.client.Get(ctx, rs.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (rs *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return rs.client.Set(ctx, rs.prefix+key, value, ttl).Err()
+}
+
+// SET NX PX: la verificación y la escritura son una sola operación en Redis
+func (rs *RedisStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return rs.client.SetNX(ctx, rs.prefix+key, value, ttl).Result()
+}
+
+func (rs *RedisStore) Delete(ctx context.Context, key string) error {
+	return rs.client.Del(ctx, rs.prefix+key).Err()
+}
+
+func (rs *RedisStore) Allow(ctx context.Context, key string, limit float64, burst int) (RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+	values, err := tokenBucketScript.Run(ctx, rs.client, []string{rs.prefix + key}, limit, burst, now).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 3 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+func (rs *RedisStore) Close() error {
+	return rs.client.Close()
+}
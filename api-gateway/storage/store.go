@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"api-gateway/config"
+)
+
+// Store es el backend compartido por el cache de respuestas y el rate limiter
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX guarda el valor solo si la key no existe, de forma atómica; devuelve si lo guardó
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// Allow consume un token del bucket identificado por key
+	Allow(ctx context.Context, key string, limit float64, burst int) (RateLimitResult, error)
+	Close() error
+}
+
+// Resultado de una verificación de rate limit
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Factory para crear el store configurado
+func New(cfg config.StorageConfig) (Store, error) {
+	switch cfg.Type {
+	case "", "memory":
+		store := NewMemoryStore()
+		store.StartCleanup()
+		return store, nil
+	case "redis":
+		return NewRedisStore(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Type)
+	}
+}
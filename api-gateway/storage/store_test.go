@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Backend bajo prueba y cómo avanzar su reloj
+type testBackend struct {
+	name    string
+	store   Store
+	advance func(d time.Duration)
+}
+
+func newTestBackends(t *testing.T) []testBackend {
+	t.Helper()
+
+	memory := NewMemoryStore()
+	t.Cleanup(func() { memory.Close() })
+
+	mr := miniredis.RunT(t)
+	redisStore, err := NewRedisStore(config.RedisConfig{Address: mr.Addr(), KeyPrefix: "test:"})
+	if err != nil {
+		t.Fatalf("NewRedisStore: %v", err)
+	}
+	t.Cleanup(func() { redisStore.Close() })
+
+	return []testBackend{
+		{name: "memory", store: memory, advance: time.Sleep},
+		{name: "redis", store: redisStore, advance: func(d time.Duration) {
+			// El token bucket usa el reloj del gateway y los TTL el de miniredis
+			time.Sleep(d)
+			mr.FastForward(d)
+		}},
+	}
+}
+
+func TestStoreGetSetDelete(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range newTestBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.store
+
+			if _, found, err := store.Get(ctx, "missing"); err != nil || found {
+				t.Fatalf("Get(missing) = found %v, err %v; want not found", found, err)
+			}
+
+			if err := store.Set(ctx, "key", []byte("value"), 0); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			value, found, err := store.Get(ctx, "key")
+			if err != nil || !found || string(value) != "value" {
+				t.Fatalf("Get(key) = %q, %v, %v; want \"value\", true, nil", value, found, err)
+			}
+
+			if err := store.Delete(ctx, "key"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, found, _ := store.Get(ctx, "key"); found {
+				t.Fatal("key still present after Delete")
+			}
+
+			// Borrar una key inexistente no es un error
+			if err := store.Delete(ctx, "key"); err != nil {
+				t.Fatalf("Delete(missing): %v", err)
+			}
+		})
+	}
+}
+
+func TestStoreTTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range newTestBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.store
+
+			if err := store.Set(ctx, "short", []byte("a"), 50*time.Millisecond); err != nil {
+				t.Fatalf("Set(short): %v", err)
+			}
+			if err := store.Set(ctx, "long", []byte("b"), time.Hour); err != nil {
+				t.Fatalf("Set(long): %v", err)
+			}
+			if _, found, _ := store.Get(ctx, "short"); !found {
+				t.Fatal("short-lived key missing before its TTL")
+			}
+
+			backend.advance(100 * time.Millisecond)
+
+			if _, found, _ := store.Get(ctx, "short"); found {
+				t.Error("short-lived key still present after its TTL")
+			}
+			if _, found, _ := store.Get(ctx, "long"); !found {
+				t.Error("long-lived key expired early")
+			}
+		})
+	}
+}
+
+func TestStoreAllowBurst(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range newTestBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.store
+
+			// Con un rate bajo el burst completo se consume sin recarga apreciable
+			for i := 0; i < 3; i++ {
+				result, err := store.Allow(ctx, "burst", 1, 3)
+				if err != nil {
+					t.Fatalf("Allow #%d: %v", i+1, err)
+				}
+				if !result.Allowed {
+					t.Fatalf("Allow #%d rejected within burst", i+1)
+				}
+				if want := 2 - i; result.Remaining != want {
+					t.Errorf("Allow #%d remaining = %d, want %d", i+1, result.Remaining, want)
+				}
+			}
+
+			result, err := store.Allow(ctx, "burst", 1, 3)
+			if err != nil {
+				t.Fatalf("Allow over burst: %v", err)
+			}
+			if result.Allowed {
+				t.Fatal("Allow over burst was accepted")
+			}
+			if result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+				t.Errorf("RetryAfter = %v, want in (0, 1s]", result.RetryAfter)
+			}
+
+			// Cada key tiene su propio bucket
+			if result, _ := store.Allow(ctx, "other", 1, 3); !result.Allowed {
+				t.Error("independent key was rejected")
+			}
+		})
+	}
+}
+
+func TestStoreAllowRefill(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range newTestBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.store
+
+			// 20 tokens por segundo: uno nuevo cada 50ms
+			if result, _ := store.Allow(ctx, "refill", 20, 1); !result.Allowed {
+				t.Fatal("first request rejected")
+			}
+			if result, _ := store.Allow(ctx, "refill", 20, 1); result.Allowed {
+				t.Fatal("second request accepted with an empty bucket")
+			}
+
+			backend.advance(120 * time.Millisecond)
+
+			if result, _ := store.Allow(ctx, "refill", 20, 1); !result.Allowed {
+				t.Fatal("request rejected after the bucket refilled")
+			}
+			// La recarga nunca supera el burst
+			if result, _ := store.Allow(ctx, "refill", 20, 1); result.Allowed {
+				t.Error("refill exceeded the burst size")
+			}
+		})
+	}
+}
+
+func TestStoreSetNX(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range newTestBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			store := backend.store
+
+			stored, err := store.SetNX(ctx, "claim", []byte("first"), 50*time.Millisecond)
+			if err != nil || !stored {
+				t.Fatalf("SetNX on a new key = %v, %v; want true, nil", stored, err)
+			}
+			stored, err = store.SetNX(ctx, "claim", []byte("second"), time.Hour)
+			if err != nil || stored {
+				t.Fatalf("SetNX on an existing key = %v, %v; want false, nil", stored, err)
+			}
+			if value, _, _ := store.Get(ctx, "claim"); string(value) != "first" {
+				t.Errorf("value = %q, want the first write", value)
+			}
+
+			// Una key vencida se puede volver a tomar
+			backend.advance(100 * time.Millisecond)
+			if stored, _ := store.SetNX(ctx, "claim", []byte("third"), time.Hour); !stored {
+				t.Error("SetNX on an expired key was rejected")
+			}
+		})
+	}
+}
+
+func TestStoreSetNXConcurrent(t *testing.T) {
+	ctx := context.Background()
+	for _, backend := range newTestBackends(t) {
+		t.Run(backend.name, func(t *testing.T) {
+			const workers = 20
+
+			var wg sync.WaitGroup
+			var winners atomic.Int32
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if stored, err := backend.store.SetNX(ctx, "race", []byte("1"), time.Hour); err == nil && stored {
+						winners.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := winners.Load(); got != 1 {
+				t.Errorf("%d concurrent SetNX calls succeeded, want exactly 1", got)
+			}
+		})
+	}
+}
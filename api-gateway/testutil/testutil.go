@@ -0,0 +1,41 @@
+// Package testutil reúne los helpers que comparten los tests de los paquetes del gateway.
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"api-gateway/config"
+)
+
+// Cargar config.json de prueba con los defaults y la validación de LoadConfig
+func LoadConfig(t testing.TB, raw string) *config.Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// Silenciar los logs del gateway durante el test
+func SilenceStdout(t testing.TB) {
+	t.Helper()
+
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = devNull
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
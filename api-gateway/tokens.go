@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"api-gateway/middleware"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+// Body de POST /auth/token
+type tokenRequest struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+}
+
+// Body de POST /auth/refresh y /auth/revoke
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+func (gw *APIGateway) issueToken(c echo.Context) error {
+	auth, err := gw.tokenIssuer()
+	if err != nil {
+		return err
+	}
+
+	var req tokenRequest
+	if err := c.Bind(&req); err != nil || req.Username == "" || req.Password == "" {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, "body must include username and password")
+	}
+
+	pair, err := auth.IssueTokens(c.Request().Context(), req.Username, req.Password)
+	if err != nil {
+		return err
+	}
+	return tokenResponse(c, pair)
+}
+
+func (gw *APIGateway) refreshToken(c echo.Context) error {
+	auth, err := gw.tokenIssuer()
+	if err != nil {
+		return err
+	}
+
+	var req refreshRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, "body must include refresh_token")
+	}
+
+	pair, err := auth.RefreshTokens(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		return err
+	}
+	return tokenResponse(c, pair)
+}
+
+func (gw *APIGateway) revokeToken(c echo.Context) error {
+	auth, err := gw.tokenIssuer()
+	if err != nil {
+		return err
+	}
+
+	var req refreshRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, "body must include refresh_token")
+	}
+
+	if err := auth.RevokeToken(c.Request().Context(), req.RefreshToken); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data:         map[string]interface{}{"revoked": true},
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+// Los endpoints de tokens solo existen si auth.users está configurado
+func (gw *APIGateway) tokenIssuer() (*middleware.AuthMiddleware, error) {
+	auth := gw.current().proxyHandler.Auth()
+	if !auth.TokenIssuanceEnabled() {
+		return nil, gatewayError(http.StatusNotFound, middleware.CodeNotFound, "token issuance is not enabled (auth.users)")
+	}
+	return auth, nil
+}
+
+// Los tokens no se deben guardar en caches intermedios (RFC 6749, sección 5.1)
+func tokenResponse(c echo.Context, pair *middleware.TokenPair) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data:         pair,
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+// Subcomando "hash-password": hash bcrypt para el archivo de auth.users.
+// El password se lee solo de stdin, para que no quede en el historial del
+// shell ni en la lista de procesos; en una terminal no se muestra al escribirlo.
+func runHashPassword(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "❌ hash-password reads the password from stdin, it takes no arguments")
+		return 1
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ error reading password: %v\n", err)
+		return 1
+	}
+	if password == "" {
+		fmt.Fprintln(os.Stderr, "❌ password is required")
+		return 1
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return 1
+	}
+	fmt.Println(string(hash))
+	return 0
+}
+
+// Password de stdin: sin eco si es una terminal, o la primera línea si viene de un pipe
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return "", scanner.Err()
+	}
+	return strings.TrimRight(scanner.Text(), "\r\n"), nil
+}
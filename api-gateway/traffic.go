@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"api-gateway/middleware"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Body de PUT /admin/services/:name/groups
+type groupWeightsRequest struct {
+	Weights map[string]int `json:"weights"`
+}
+
+func (gw *APIGateway) getBackendGroups(c echo.Context) error {
+	name := c.Param("name")
+	splitter, ok := gw.current().proxyHandler.TrafficSplitter(name)
+	if !ok {
+		return gatewayError(http.StatusNotFound, middleware.CodeNotFound, fmt.Sprintf("service %s has no backend groups", name))
+	}
+
+	weights := splitter.Weights()
+	groups := make([]map[string]interface{}, 0, len(weights))
+	for _, group := range splitter.Groups() {
+		groups = append(groups, map[string]interface{}{
+			"name":     group,
+			"weight":   weights[group],
+			"backends": splitter.GroupBackends(group),
+		})
+	}
+
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data: map[string]interface{}{
+			"service": name,
+			"groups":  groups,
+		},
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+// Los pesos cambiados en caliente sobreviven a las recargas mientras config.json no cambie los grupos del servicio
+func (gw *APIGateway) updateBackendGroups(c echo.Context) error {
+	name := c.Param("name")
+	splitter, ok := gw.current().proxyHandler.TrafficSplitter(name)
+	if !ok {
+		return gatewayError(http.StatusNotFound, middleware.CodeNotFound, fmt.Sprintf("service %s has no backend groups", name))
+	}
+
+	var req groupWeightsRequest
+	if err := c.Bind(&req); err != nil || len(req.Weights) == 0 {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, "body must include weights, e.g. {\"weights\": {\"stable\": 90, \"canary\": 10}}")
+	}
+
+	if err := splitter.SetWeights(req.Weights); err != nil {
+		return gatewayError(http.StatusBadRequest, middleware.CodeBadRequest, err.Error())
+	}
+
+	weights := splitter.Weights()
+	fmt.Printf("⚖️  Backend group weights for %s updated: %v\n", name, weights)
+
+	return c.JSON(http.StatusOK, GatewayResponse{
+		Data: map[string]interface{}{
+			"service": name,
+			"weights": weights,
+		},
+		Success:      true,
+		ErrorMessage: nil,
+	})
+}
+
+// Error de la API de administración; lo escribe el error handler central
+func gatewayError(status int, code, message string) error {
+	return middleware.NewGatewayError(status, middleware.LayerGateway, code, message)
+}